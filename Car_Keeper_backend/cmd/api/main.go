@@ -4,48 +4,59 @@ import (
 	"Car_Keeper/internal/config"
 	"Car_Keeper/internal/database"
 	"Car_Keeper/internal/handler"
+	"Car_Keeper/internal/health"
 	"Car_Keeper/internal/middleware"
+	"Car_Keeper/internal/models"
 	"Car_Keeper/internal/repository"
 	"Car_Keeper/internal/service"
+	"Car_Keeper/pkg/response"
+	"Car_Keeper/pkg/utils"
 	"context"
-	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
 )
 
+// probePaths are served without request spans
+var probePaths = map[string]bool{"/livez": true, "/readyz": true, "/health": true}
+
 func main() {
 	// Load configuration
 	cfg := config.Load()
 
-	// Start tracing provider
-	tracerProvider, err := startTracing()
-	if err != nil {
-		log.Fatalf("Failed to start tracing: %v", err)
+	// `api migrate ...` manages the schema and exits without serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
 	}
 
-	// Shutdown tracing provider
-	defer func() {
-		if err := tracerProvider.Shutdown(context.Background()); err != nil {
-			log.Fatalf("Failed to shutdown tracer provider: %v", err)
+	// `api seed ...` loads fixture files into the database and exits
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		if err := runSeed(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("seed: %v", err)
 		}
-	}()
+		return
+	}
+
+	// Start tracing provider, the API keeps running without exporting when that fails
+	tracerProvider, err := startTracing(cfg)
+	if err != nil {
+		log.Printf("Traces are not exported: %v", err)
+	}
 
 	// Set global tracer provider
 	otel.SetTracerProvider(tracerProvider)
 
-	// Initialize database (with auto-migration and optional schema)
+	// Initialize database and apply pending migrations
 	db, err := database.InitDatabase(cfg)
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
@@ -55,52 +66,186 @@ func main() {
 	// Initialize repositories
 	carRepo := repository.NewCarRepository(db)
 	engineRepo := repository.NewEngineRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	trashRepo := repository.NewTrashRepository(db)
 
 	// Initialize services
-	carService := service.NewCarService(carRepo)
+	carService := service.NewCarService(carRepo, engineRepo)
 	engineService := service.NewEngineService(engineRepo)
+	tokenService := service.NewTokenService(tokenRepo, userRepo, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	userService := service.NewUserService(userRepo, tokenService)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
+	trashService := service.NewTrashService(trashRepo, cfg.TrashRetentionDays)
+
+	// Hard delete trash older than the retention and prune expired tokens, hourly, until shutdown
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	jobs.Add(2)
+	go func() {
+		defer jobs.Done()
+		trashService.RunRetention(jobsCtx, time.Hour)
+	}()
+	go func() {
+		defer jobs.Done()
+		tokenService.RunPruning(jobsCtx, time.Hour)
+	}()
+
+	// JWT keys, RS256/EdDSA from PEM files or HS256 with the shared secret
+	keySet, err := utils.LoadKeySet(utils.KeyConfig{
+		Secret:               cfg.JWTSecret,
+		SigningKeyFile:       cfg.JWTSigningKeyFile,
+		SigningKeyID:         cfg.JWTSigningKeyID,
+		VerificationKeyFiles: cfg.JWTVerificationKeyFiles,
+	})
+	if err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+	utils.SetKeySet(keySet)
+
+	// Reject revoked access tokens in ValidateToken
+	utils.SetRevocationList(tokenService)
 
 	// Initialize handlers
 	carHandler := handler.NewCarHandler(carService)
 	engineHandler := handler.NewEngineHandler(engineService)
+	userHandler := handler.NewUserHandler(userService, tokenService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	trashHandler := handler.NewTrashHandler(trashService)
 
-	// Setup Gin router
-	router := gin.Default()
+	// Setup Gin router, panics and unknown routes answer with problem+json too
+	router := gin.New()
+	// ClientIP honours X-Forwarded-For only from Traefik and other trusted proxies
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	router.Use(gin.Logger())
+	router.Use(gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		response.Error(c, http.StatusInternalServerError, "An unexpected error occurred")
+	}))
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) {
+		response.Error(c, http.StatusNotFound, "Route not found")
+	})
+	router.NoMethod(func(c *gin.Context) {
+		response.Error(c, http.StatusMethodNotAllowed, "Method not allowed")
+	})
 
 	// Middleware
 	router.Use(middleware.CORS())
 	router.Use(middleware.Logger())
 
-	// Use OpenTelemetry middleware for Gin
-	router.Use(otelgin.Middleware("Car-Keeper"))
+	// Use OpenTelemetry middleware for Gin, the probes are polled too often to trace
+	router.Use(otelgin.Middleware(cfg.ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return !probePaths[r.URL.Path]
+	})))
 	router.Use(middleware.MetricsMiddleware())
 
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
+	// Probes, readiness checks the database and the schema version
+	healthRegistry := health.NewRegistry(cfg.HealthCheckTimeout)
+	healthRegistry.Register("database", database.PingCheck(db))
+	migrationsCheck, err := database.MigrationsCheck(db)
+	if err != nil {
+		log.Fatal("Failed to load migrations:", err)
+	}
+	healthRegistry.Register("migrations", migrationsCheck)
+	healthHandler := handler.NewHealthHandler(healthRegistry)
+
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
+	router.GET("/health", healthHandler.Livez)
+
+	// Public keys for services verifying our tokens
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(200, keySet.JWKS())
 	})
 
 	// Prometheus metrics endpoint
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Reads are public, bulk exports and writes need a token or API key with the matching scope
+	authenticated := middleware.AuthMiddleware(apiKeyService)
+	canReadCars := middleware.RequireScope(models.ScopeCarsRead)
+	canWriteCars := middleware.RequireScope(models.ScopeCarsWrite)
+	canReadEngines := middleware.RequireScope(models.ScopeEnginesRead)
+	canWriteEngines := middleware.RequireScope(models.ScopeEnginesWrite)
+	isAdmin := middleware.RequireRole(models.RoleAdmin)
+	isUser := middleware.RequireUser()
+
+	// Each route group has its own quota per API key, user or client IP. Every
+	// client IP has an overall quota checked before credentials are looked up,
+	// and the auth routes count per IP so rotating credentials does not help.
+	rateLimitStore := middleware.NewMemoryRateLimitStore()
+	rateLimit := func(group string, byIP bool) gin.HandlerFunc {
+		if !cfg.RateLimitEnabled {
+			return func(c *gin.Context) { c.Next() }
+		}
+		rl, ok := cfg.RateLimits[group]
+		if !ok {
+			rl = cfg.RateLimits["default"]
+		}
+		if byIP {
+			return middleware.RateLimitIP(rateLimitStore, group, rl)
+		}
+		return middleware.RateLimit(rateLimitStore, group, rl)
+	}
+	limit := func(group string) gin.HandlerFunc { return rateLimit(group, false) }
+	limitIP := func(group string) gin.HandlerFunc { return rateLimit(group, true) }
+
 	// API routes
-	v1 := router.Group("/api/v1")
+	v1 := router.Group("/api/v1", limitIP("ip"), middleware.Identify(apiKeyService))
 	{
 		// Car routes
-		cars := v1.Group("/cars")
+		cars := v1.Group("/cars", limit("cars"))
 		{
 			cars.GET("/:carid", carHandler.GetCarByID)
-			cars.GET("/", carHandler.GetCarByBrand)
-			cars.POST("/", carHandler.CreateCar)
-			cars.PUT("/:carid", carHandler.UpdateCar)
-			cars.DELETE("/:carid", carHandler.DeleteCar)
+			cars.GET("/", carHandler.ListCars)
+			cars.GET("/export", authenticated, canReadCars, carHandler.ExportCars)
+			cars.POST("/", authenticated, canWriteCars, carHandler.CreateCar)
+			cars.POST("/import", authenticated, canWriteCars, carHandler.ImportCars)
+			cars.PUT("/:carid", authenticated, canWriteCars, carHandler.UpdateCar)
+			cars.PATCH("/:carid", authenticated, canWriteCars, carHandler.PatchCar)
+			cars.DELETE("/:carid", authenticated, canWriteCars, carHandler.DeleteCar)
 		}
-		engine := v1.Group("/engines")
+		engine := v1.Group("/engines", limit("engines"))
 		{
 			engine.GET("/:engineid", engineHandler.GetEngineByID)
-			engine.POST("/", engineHandler.CreateEngine)
-			engine.PUT("/:engineid", engineHandler.UpdateEngine)
-			engine.DELETE("/:engineid", engineHandler.DeleteEngine)
+			engine.GET("/", engineHandler.ListEngines)
+			engine.GET("/export", authenticated, canReadEngines, engineHandler.ExportEngines)
+			engine.POST("/", authenticated, canWriteEngines, engineHandler.CreateEngine)
+			engine.PUT("/:engineid", authenticated, canWriteEngines, engineHandler.UpdateEngine)
+			engine.PATCH("/:engineid", authenticated, canWriteEngines, engineHandler.PatchEngine)
+			engine.DELETE("/:engineid", authenticated, canWriteEngines, engineHandler.DeleteEngine)
+		}
+		auth := v1.Group("/auth", limitIP("auth"))
+		{
+			auth.POST("/register", userHandler.Register)
+			auth.POST("/login", userHandler.Login)
+			auth.POST("/refresh", userHandler.Refresh)
+			auth.POST("/logout", authenticated, userHandler.Logout)
+		}
+		users := v1.Group("/users", limit("users"), authenticated)
+		{
+			users.GET("/me", isUser, userHandler.GetMe)
+			users.PUT("/me", isUser, userHandler.UpdateMe)
+			users.PUT("/me/password", isUser, userHandler.ChangePassword)
+			users.PUT("/:userid/role", isAdmin, userHandler.UpdateRole)
+		}
+		admin := v1.Group("/admin", limit("admin"), authenticated, isAdmin)
+		{
+			admin.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+			admin.GET("/api-keys", apiKeyHandler.ListAPIKeys)
+			admin.DELETE("/api-keys/:keyid", apiKeyHandler.RevokeAPIKey)
+
+			// Soft-deleted cars and engines
+			admin.GET("/trash/cars", trashHandler.ListDeletedCars)
+			admin.POST("/trash/cars/:carid/restore", trashHandler.RestoreCar)
+			admin.DELETE("/trash/cars/:carid", trashHandler.PurgeCar)
+			admin.GET("/trash/engines", trashHandler.ListDeletedEngines)
+			admin.POST("/trash/engines/:engineid/restore", trashHandler.RestoreEngine)
+			admin.DELETE("/trash/engines/:engineid", trashHandler.PurgeEngine)
 		}
 	}
 
@@ -110,42 +255,31 @@ func main() {
 		port = "8000"
 	}
 
+	srv := newServer(cfg, ":"+port, router)
 	log.Printf("Server is Started on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	serveErr := serve(cfg, srv, healthRegistry.SetShuttingDown)
+	if serveErr != nil {
+		log.Printf("Server stopped: %v", serveErr)
 	}
-}
 
-func startTracing() (*trace.TracerProvider, error) {
-	header := map[string]string{
-		"Content-Type": "application/json",
-	}
+	// Shut down in order: background jobs, then tracing, then the connection pool
+	stopJobs()
+	jobs.Wait()
 
-	exporter, err := otlptrace.New(
-		context.Background(),
-		otlptracehttp.NewClient(
-			otlptracehttp.WithEndpoint("jaeger:4318"),
-			otlptracehttp.WithHeaders(header),
-			otlptracehttp.WithInsecure(),
-		))
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to create exporter: %w", err)
+	// Spans still queued are dropped when the collector does not answer in time
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shutdown tracer provider: %v", err)
 	}
 
-	tracerProvider := trace.NewTracerProvider(
-		trace.WithBatcher(
-			exporter,
-			trace.WithMaxExportBatchSize(trace.DefaultMaxExportBatchSize),
-			trace.WithBatchTimeout(trace.DefaultScheduleDelay*time.Millisecond),
-		),
-		trace.WithResource(
-			resource.NewWithAttributes(
-				semconv.SchemaURL,
-				semconv.ServiceNameKey.String("Car-Keeper"),
-			),
-		),
-	)
-
-	return tracerProvider, nil
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Printf("Failed to close database connections: %v", err)
+		}
+	}
+	log.Println("Server exited")
+	if serveErr != nil {
+		os.Exit(1)
+	}
 }
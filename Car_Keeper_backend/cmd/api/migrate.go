@@ -0,0 +1,88 @@
+package main
+
+import (
+	"Car_Keeper/internal/config"
+	"Car_Keeper/internal/database"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = "usage: api migrate up | down [steps] | status | create [-dir dir] <name>"
+
+// runMigrate handles the migrate subcommand
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	// create only writes files, it needs no database
+	if args[0] == "create" {
+		flags := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+		dir := flags.String("dir", "internal/database/migrations", "directory of the migration files")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return errors.New(migrateUsage)
+		}
+		up, down, err := database.CreateMigration(*dir, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created %s\nCreated %s\n", up, down)
+		return nil
+	}
+
+	db, err := database.NewPostgresDB(cfg)
+	if err != nil {
+		return err
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("Rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"Car_Keeper/internal/config"
+	"Car_Keeper/internal/database"
+	"context"
+	"fmt"
+)
+
+// runSeed handles the seed subcommand, `api seed [file or dir ...]` loads the
+// fixtures directory when no path is given
+func runSeed(cfg *config.Config, paths []string) error {
+	if len(paths) == 0 {
+		paths = []string{"fixtures"}
+	}
+
+	fixtures, err := database.LoadFixtures(paths...)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.InitDatabase(cfg)
+	if err != nil {
+		return err
+	}
+
+	results, err := database.Seed(context.Background(), db, fixtures)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		fmt.Printf("%-8s %d created, %d updated, %d restored, %d skipped\n", r.Kind, r.Created, r.Updated, r.Restored, r.Skipped)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"Car_Keeper/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func newServer(cfg *config.Config, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+	}
+}
+
+// serve runs srv until it fails or SIGINT/SIGTERM arrives. On a signal it calls
+// onShutdown, keeps serving for cfg.ShutdownDelay, then stops accepting
+// connections and waits up to cfg.ShutdownTimeout for in-flight requests.
+func serve(cfg *config.Config, srv *http.Server, onShutdown func()) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+	// A second signal kills the process right away
+	stop()
+
+	onShutdown()
+	if cfg.ShutdownDelay > 0 {
+		log.Printf("Shutting down, still serving for %s", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	log.Printf("Shutting down, draining connections for up to %s", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to drain connections: %w", err)
+	}
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
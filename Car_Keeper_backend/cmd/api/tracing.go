@@ -0,0 +1,185 @@
+package main
+
+import (
+	"Car_Keeper/internal/config"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+)
+
+// exportRetry bounds how long a batch is retried, so an unreachable collector
+// costs dropped spans instead of an ever growing backlog
+var exportRetry = struct {
+	initial, max, elapsed time.Duration
+}{initial: time.Second, max: 10 * time.Second, elapsed: 30 * time.Second}
+
+// exportErrorLogInterval throttles the log lines of failing exports
+const exportErrorLogInterval = time.Minute
+
+// startTracing sets up the tracer provider described by cfg. When the exporter
+// cannot be created the error is returned together with a provider that still
+// samples but exports nothing, so the API runs without a collector.
+func startTracing(cfg *config.Config) (*trace.TracerProvider, error) {
+	res := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(cfg.ServiceVersion),
+		semconv.DeploymentEnvironmentName(cfg.DeploymentEnvironment),
+	)
+	opts := []trace.TracerProviderOption{
+		trace.WithResource(res),
+		trace.WithSampler(trace.ParentBased(trace.TraceIDRatioBased(cfg.TracesSamplerRatio))),
+	}
+
+	otel.SetErrorHandler(throttledErrorHandler(exportErrorLogInterval))
+	// Continue the traces of callers, the parent based sampler follows their decision
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter, err := newTraceExporter(cfg)
+	if err == nil && exporter != nil {
+		opts = append(opts, trace.WithBatcher(
+			exporter,
+			trace.WithMaxExportBatchSize(trace.DefaultMaxExportBatchSize),
+			trace.WithBatchTimeout(trace.DefaultScheduleDelay*time.Millisecond),
+		))
+	}
+	return trace.NewTracerProvider(opts...), err
+}
+
+// newTraceExporter returns the exporter selected by cfg.TracesExporter, nil for none
+func newTraceExporter(cfg *config.Config) (trace.SpanExporter, error) {
+	switch strings.ToLower(cfg.TracesExporter) {
+	case "none":
+		return nil, nil
+	case "stdout", "console":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case "otlp", "":
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q, use otlp, stdout or none", cfg.TracesExporter)
+	}
+
+	endpoint, err := parseOTLPEndpoint(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var client otlptrace.Client
+	switch strings.ToLower(cfg.OTLPProtocol) {
+	case "grpc":
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(endpoint.host),
+			otlptracegrpc.WithHeaders(cfg.OTLPHeaders),
+			otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+				Enabled:         true,
+				InitialInterval: exportRetry.initial,
+				MaxInterval:     exportRetry.max,
+				MaxElapsedTime:  exportRetry.elapsed,
+			}),
+		}
+		if endpoint.insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		client = otlptracegrpc.NewClient(opts...)
+	case "http/protobuf", "http", "":
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(endpoint.host),
+			otlptracehttp.WithURLPath(endpoint.path),
+			otlptracehttp.WithHeaders(cfg.OTLPHeaders),
+			otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+				Enabled:         true,
+				InitialInterval: exportRetry.initial,
+				MaxInterval:     exportRetry.max,
+				MaxElapsedTime:  exportRetry.elapsed,
+			}),
+		}
+		if endpoint.insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		client = otlptracehttp.NewClient(opts...)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q, use http/protobuf or grpc", cfg.OTLPProtocol)
+	}
+
+	exporter, err := otlptrace.New(context.Background(), client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// otlpTracesPath is the path of the OTLP/HTTP traces endpoint
+const otlpTracesPath = "/v1/traces"
+
+// otlpEndpoint is where the OTLP exporter sends spans
+type otlpEndpoint struct {
+	host     string // host:port
+	path     string // URL path, only used over HTTP
+	insecure bool
+}
+
+// parseOTLPEndpoint reads cfg.OTLPEndpoint like the OpenTelemetry SDK reads
+// OTEL_EXPORTER_OTLP_ENDPOINT: a URL whose scheme picks plaintext (http) or TLS
+// (https), with /v1/traces as the path when it has none. A bare host:port uses
+// TLS unless cfg.OTLPInsecure is set.
+func parseOTLPEndpoint(cfg *config.Config) (otlpEndpoint, error) {
+	if !strings.Contains(cfg.OTLPEndpoint, "://") {
+		return otlpEndpoint{host: cfg.OTLPEndpoint, path: otlpTracesPath, insecure: cfg.OTLPInsecure}, nil
+	}
+
+	u, err := url.Parse(cfg.OTLPEndpoint)
+	if err != nil {
+		return otlpEndpoint{}, fmt.Errorf("invalid OTLP endpoint %q: %w", cfg.OTLPEndpoint, err)
+	}
+	endpoint := otlpEndpoint{host: u.Host, path: u.Path}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		endpoint.insecure = true
+	case "https":
+	default:
+		return otlpEndpoint{}, fmt.Errorf("invalid OTLP endpoint %q, the scheme must be http or https", cfg.OTLPEndpoint)
+	}
+	if endpoint.path == "" || endpoint.path == "/" {
+		endpoint.path = otlpTracesPath
+	}
+	return endpoint, nil
+}
+
+// throttledErrorHandler logs OpenTelemetry errors, at most one per interval,
+// so a missing collector does not flood the log
+func throttledErrorHandler(interval time.Duration) otel.ErrorHandler {
+	var (
+		mu      sync.Mutex
+		last    time.Time
+		dropped int
+	)
+	return otel.ErrorHandlerFunc(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if time.Since(last) < interval {
+			dropped++
+			return
+		}
+		if dropped > 0 {
+			log.Printf("OpenTelemetry error (%d more since the last one): %v", dropped, err)
+		} else {
+			log.Printf("OpenTelemetry error: %v", err)
+		}
+		last, dropped = time.Now(), 0
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"Car_Keeper/internal/config"
+	"testing"
+)
+
+func TestParseOTLPEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		insecure bool // OTEL_EXPORTER_OTLP_INSECURE
+		want     otlpEndpoint
+		wantErr  bool
+	}{
+		{endpoint: "http://jaeger:4318", want: otlpEndpoint{host: "jaeger:4318", path: "/v1/traces", insecure: true}},
+		{endpoint: "http://jaeger:4318/", want: otlpEndpoint{host: "jaeger:4318", path: "/v1/traces", insecure: true}},
+		{endpoint: "https://otlp.example.com", want: otlpEndpoint{host: "otlp.example.com", path: "/v1/traces"}},
+		{endpoint: "https://otlp.example.com", insecure: true, want: otlpEndpoint{host: "otlp.example.com", path: "/v1/traces"}},
+		{endpoint: "https://otlp.example.com/custom/traces", want: otlpEndpoint{host: "otlp.example.com", path: "/custom/traces"}},
+		{endpoint: "jaeger:4318", want: otlpEndpoint{host: "jaeger:4318", path: "/v1/traces"}},
+		{endpoint: "jaeger:4318", insecure: true, want: otlpEndpoint{host: "jaeger:4318", path: "/v1/traces", insecure: true}},
+		{endpoint: "ftp://jaeger:4318", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseOTLPEndpoint(&config.Config{OTLPEndpoint: tt.endpoint, OTLPInsecure: tt.insecure})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseOTLPEndpoint(%q) accepted, want an error", tt.endpoint)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseOTLPEndpoint(%q): %v", tt.endpoint, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseOTLPEndpoint(%q) = %+v, want %+v", tt.endpoint, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package apperrors defines the domain errors shared by the repository, service
+// and handler layers. Handlers map the error kinds to HTTP statuses.
+package apperrors
+
+import (
+	"errors"
+)
+
+// Error kinds, match them with errors.Is
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrInvalidID           = errors.New("invalid id")
+	ErrInvalidQuery        = errors.New("invalid query")
+	ErrConflict            = errors.New("conflict")
+	ErrValidation          = errors.New("validation failed")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrForbidden           = errors.New("forbidden")
+	ErrPreconditionFailed  = errors.New("precondition failed")
+)
+
+// Error is a domain error of a given Kind. Message and Field are safe to show
+// to clients, Err is the underlying cause and is only meant for logs.
+type Error struct {
+	Kind    error
+	Message string
+	Field   string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+func New(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func Wrap(kind error, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// FieldError is an error caused by one field of the request body
+func FieldError(kind error, field, message string) *Error {
+	return &Error{Kind: kind, Message: message, Field: field}
+}
+
+func NotFound(resource string) *Error {
+	return New(ErrNotFound, resource+" not found")
+}
+
+func InvalidID(resource string, err error) *Error {
+	return Wrap(ErrInvalidID, "invalid "+resource+" ID", err)
+}
+
+// VersionMismatch reports a write whose If-Match version is no longer current
+func VersionMismatch(resource string) *Error {
+	return New(ErrPreconditionFailed, resource+" has been modified since it was read")
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,7 +18,50 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	JWTSecret  string
-	Port       string
+	// PEM key files for RS256/EdDSA signing, JWTSecret (HS256) is used when unset
+	JWTSigningKeyFile       string
+	JWTSigningKeyID         string
+	JWTVerificationKeyFiles []string
+	AccessTokenTTL          time.Duration
+	RefreshTokenTTL         time.Duration
+	Port                    string
+	// Days soft-deleted cars and engines stay in the trash, 0 keeps them forever
+	TrashRetentionDays int
+
+	// Tracing, the OTEL_* variables follow the OpenTelemetry SDK conventions
+	TracesExporter        string // otlp, stdout or none
+	OTLPEndpoint          string // a URL whose scheme decides on TLS, or host:port
+	OTLPProtocol          string // http/protobuf or grpc
+	OTLPInsecure          bool   // plaintext for a host:port endpoint
+	OTLPHeaders           map[string]string
+	TracesSamplerRatio    float64 // share of new traces that are sampled, children follow their parent
+	ServiceName           string
+	ServiceVersion        string
+	DeploymentEnvironment string
+
+	// HTTP server limits and the time in-flight requests get to finish on shutdown
+	HTTPReadTimeout       time.Duration
+	HTTPReadHeaderTimeout time.Duration
+	HTTPWriteTimeout      time.Duration
+	HTTPIdleTimeout       time.Duration
+	HTTPMaxHeaderBytes    int
+	ShutdownTimeout       time.Duration
+	// Time between failing readiness and draining, so load balancers can react first
+	ShutdownDelay      time.Duration
+	HealthCheckTimeout time.Duration
+
+	// Rate limits per route group, "default" applies to groups without their own
+	// and "ip" to all API requests of a client IP before they are authenticated
+	RateLimitEnabled bool
+	RateLimits       map[string]RateLimit
+	// Proxies (IPs or CIDRs) whose X-Forwarded-For is trusted for the client IP, none by default
+	TrustedProxies []string
+}
+
+// RateLimit allows Requests per Period, in bursts of up to Requests
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
 }
 
 func Load() *Config {
@@ -24,13 +71,39 @@ func Load() *Config {
 	}
 
 	return &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "caruser"),
-		DBPassword: getEnv("DB_PASSWORD", "carpassword"),
-		DBName:     getEnv("DB_NAME", "car"),
-		JWTSecret:  getEnv("JWT_SECRET", "BetterCallSoul"),
-		Port:       getEnv("PORT", "8080"),
+		DBHost:                  getEnv("DB_HOST", "localhost"),
+		DBPort:                  getEnv("DB_PORT", "5432"),
+		DBUser:                  getEnv("DB_USER", "caruser"),
+		DBPassword:              getEnv("DB_PASSWORD", "carpassword"),
+		DBName:                  getEnv("DB_NAME", "car"),
+		JWTSecret:               getEnv("JWT_SECRET", "BetterCallSoul"),
+		JWTSigningKeyFile:       getEnv("JWT_SIGNING_KEY_FILE", ""),
+		JWTSigningKeyID:         getEnv("JWT_SIGNING_KEY_ID", ""),
+		JWTVerificationKeyFiles: getListEnv("JWT_VERIFICATION_KEY_FILES"),
+		AccessTokenTTL:          getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:         getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		Port:                    getEnv("PORT", "8080"),
+		TrashRetentionDays:      getIntEnv("TRASH_RETENTION_DAYS", 30),
+		TracesExporter:          getEnv("OTEL_TRACES_EXPORTER", "otlp"),
+		OTLPEndpoint:            getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://jaeger:4318"),
+		OTLPProtocol:            getEnv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf"),
+		OTLPInsecure:            getBoolEnv("OTEL_EXPORTER_OTLP_INSECURE", false),
+		OTLPHeaders:             getMapEnv("OTEL_EXPORTER_OTLP_HEADERS"),
+		TracesSamplerRatio:      getFloatEnv("OTEL_TRACES_SAMPLER_ARG", 1),
+		ServiceName:             getEnv("OTEL_SERVICE_NAME", "Car-Keeper"),
+		ServiceVersion:          getEnv("SERVICE_VERSION", "dev"),
+		DeploymentEnvironment:   getEnv("DEPLOYMENT_ENVIRONMENT", "development"),
+		HTTPReadTimeout:         getDurationEnv("HTTP_READ_TIMEOUT", 30*time.Second),
+		HTTPReadHeaderTimeout:   getDurationEnv("HTTP_READ_HEADER_TIMEOUT", 10*time.Second),
+		HTTPWriteTimeout:        getDurationEnv("HTTP_WRITE_TIMEOUT", 60*time.Second),
+		HTTPIdleTimeout:         getDurationEnv("HTTP_IDLE_TIMEOUT", 120*time.Second),
+		HTTPMaxHeaderBytes:      getIntEnv("HTTP_MAX_HEADER_BYTES", 1<<20),
+		ShutdownTimeout:         getDurationEnv("SHUTDOWN_TIMEOUT", 25*time.Second),
+		ShutdownDelay:           getDurationEnv("SHUTDOWN_DELAY", 5*time.Second),
+		HealthCheckTimeout:      getDurationEnv("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		RateLimitEnabled:        getBoolEnv("RATE_LIMIT_ENABLED", true),
+		RateLimits:              getRateLimitsEnv("RATE_LIMITS", "default=300/1m,auth=20/1m,ip=600/1m"),
+		TrustedProxies:          getListEnv("TRUSTED_PROXIES"),
 	}
 }
 
@@ -40,3 +113,110 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getListEnv splits a comma separated variable, skipping empty entries
+func getListEnv(key string) []string {
+	return splitList(os.Getenv(key))
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration %q for %s, using %s", value, key, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid number %q for %s, using %d", value, key, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean %q for %s, using %t", value, key, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid number %q for %s, using %g", value, key, defaultValue)
+		return defaultValue
+	}
+	return f
+}
+
+// getMapEnv parses a comma separated list of key=value pairs with percent-encoded
+// values, the format of OTEL_EXPORTER_OTLP_HEADERS
+func getMapEnv(key string) map[string]string {
+	values := map[string]string{}
+	for _, pair := range getListEnv(key) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			log.Printf("Ignoring %q in %s, expected key=value", pair, key)
+			continue
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(v))
+		if err != nil {
+			log.Printf("Ignoring %q in %s, the value is not percent-encoded", strings.TrimSpace(k), key)
+			continue
+		}
+		values[strings.TrimSpace(k)] = decoded
+	}
+	return values
+}
+
+// getRateLimitsEnv parses group=requests/period pairs like "cars=100/1m". The
+// variable's entries override the ones of defaultValue.
+func getRateLimitsEnv(key, defaultValue string) map[string]RateLimit {
+	limits := map[string]RateLimit{}
+	for _, value := range []string{defaultValue, os.Getenv(key)} {
+		for _, pair := range splitList(value) {
+			group, spec, _ := strings.Cut(pair, "=")
+			requests, period, _ := strings.Cut(spec, "/")
+			n, err := strconv.Atoi(strings.TrimSpace(requests))
+			d, perr := time.ParseDuration(strings.TrimSpace(period))
+			if err != nil || perr != nil || n <= 0 || d <= 0 {
+				log.Printf("Ignoring %q in %s, expected group=requests/period", pair, key)
+				continue
+			}
+			limits[strings.TrimSpace(group)] = RateLimit{Requests: n, Period: d}
+		}
+	}
+	return limits
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetMapEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]string
+	}{
+		{value: "", want: map[string]string{}},
+		{value: "api-key=secret", want: map[string]string{"api-key": "secret"}},
+		{value: " a = 1 , b=2", want: map[string]string{"a": "1", "b": "2"}},
+		{value: "Authorization=Basic%20dXNlcjpwYXNz", want: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}},
+		{value: "token=a%3Db", want: map[string]string{"token": "a=b"}},
+		{value: "broken,bad=%zz,ok=1", want: map[string]string{"ok": "1"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_HEADERS", tt.value)
+		if got := getMapEnv("TEST_HEADERS"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getMapEnv(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
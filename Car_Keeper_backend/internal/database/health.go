@@ -0,0 +1,42 @@
+package database
+
+import (
+	"Car_Keeper/internal/health"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// PingCheck fails when no connection to Postgres can be used
+func PingCheck(db *gorm.DB) health.Check {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return fmt.Errorf("database ping failed: %w", err)
+		}
+		return nil
+	}
+}
+
+// MigrationsCheck fails while migrations embedded in this build are not applied,
+// e.g. when a newer pod runs against an old schema
+func MigrationsCheck(db *gorm.DB) (health.Check, error) {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) error {
+		pending, err := migrator.Pending(WithoutTracing(ctx))
+		if err != nil {
+			return fmt.Errorf("reading applied migrations failed: %w", err)
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d migrations pending, the first is %04d_%s", len(pending), pending[0].Version, pending[0].Name)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS engines (
+    engine_id       uuid PRIMARY KEY,
+    displacement    bigint NOT NULL,
+    no_of_cylinders bigint NOT NULL,
+    car_range       bigint NOT NULL,
+    created_at      timestamptz,
+    updated_at      timestamptz,
+    deleted_at      timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_engines_deleted_at ON engines (deleted_at);
+
+CREATE TABLE IF NOT EXISTS cars (
+    id         uuid PRIMARY KEY,
+    name       text NOT NULL,
+    year       varchar(4) NOT NULL,
+    brand      text NOT NULL,
+    fuel_type  text NOT NULL,
+    price      decimal NOT NULL,
+    engine_id  uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    CONSTRAINT fk_cars_engine FOREIGN KEY (engine_id) REFERENCES engines (engine_id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_cars_deleted_at ON cars (deleted_at);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS users (
+    id            bigserial PRIMARY KEY,
+    email         text NOT NULL,
+    password_hash text NOT NULL,
+    name          text NOT NULL,
+    phone         text,
+    role          text NOT NULL DEFAULT 'viewer',
+    created_at    timestamptz,
+    updated_at    timestamptz,
+    deleted_at    timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id          uuid PRIMARY KEY,
+    user_id     bigint NOT NULL,
+    family_id   uuid NOT NULL,
+    token_hash  text NOT NULL,
+    expires_at  timestamptz NOT NULL,
+    replaced_by uuid,
+    revoked_at  timestamptz,
+    created_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    id         text PRIMARY KEY,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE cars DROP COLUMN IF EXISTS version;
+ALTER TABLE engines DROP COLUMN IF EXISTS version;
@@ -0,0 +1,2 @@
+ALTER TABLE engines ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
@@ -0,0 +1,267 @@
+package database
+
+import (
+	"Car_Keeper/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/goccy/go-yaml"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Fixtures are the records a seed run inserts, loaded from YAML or JSON files
+type Fixtures struct {
+	Engines []EngineFixture `json:"engines" yaml:"engines"`
+	Cars    []CarFixture    `json:"cars" yaml:"cars"`
+	Users   []UserFixture   `json:"users" yaml:"users"`
+}
+
+type EngineFixture struct {
+	EngineID             uuid.UUID `json:"engine_id" yaml:"engine_id" binding:"required"`
+	models.EngineRequest `yaml:",inline"`
+}
+
+type CarFixture struct {
+	ID                uuid.UUID `json:"id" yaml:"id" binding:"required"`
+	models.CarRequest `yaml:",inline"`
+}
+
+type UserFixture struct {
+	Email    string `json:"email" yaml:"email" binding:"required,email"`
+	Password string `json:"password" yaml:"password" binding:"required,min=6"`
+	Name     string `json:"name" yaml:"name" binding:"required"`
+	Phone    string `json:"phone" yaml:"phone"`
+	Role     string `json:"role" yaml:"role" binding:"omitempty,oneof=viewer editor admin"`
+}
+
+// SeedResult counts the records of one kind a seed run created, updated,
+// restored from the trash and skipped because they already matched their fixture
+type SeedResult struct {
+	Kind     string
+	Created  int
+	Updated  int
+	Restored int
+	Skipped  int
+}
+
+// fixtureValidator checks fixtures with the same binding rules as the API requests
+var fixtureValidator = func() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	return v
+}()
+
+// LoadFixtures reads the .yaml, .yml and .json files at paths, directories are
+// read in file name order. The fixtures of all files are merged.
+func LoadFixtures(paths ...string) (*Fixtures, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && isFixtureFile(entry.Name()) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+
+	all := &Fixtures{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var fixtures Fixtures
+		if strings.EqualFold(filepath.Ext(file), ".json") {
+			err = json.Unmarshal(data, &fixtures)
+		} else {
+			err = yaml.Unmarshal(data, &fixtures)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if err := fixtures.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		all.Engines = append(all.Engines, fixtures.Engines...)
+		all.Cars = append(all.Cars, fixtures.Cars...)
+		all.Users = append(all.Users, fixtures.Users...)
+	}
+	return all, nil
+}
+
+func isFixtureFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+func (f *Fixtures) validate() error {
+	for i := range f.Engines {
+		if err := fixtureValidator.Struct(&f.Engines[i]); err != nil {
+			return fmt.Errorf("engines[%d]: %w", i, err)
+		}
+	}
+	for i := range f.Cars {
+		if err := fixtureValidator.Struct(&f.Cars[i]); err != nil {
+			return fmt.Errorf("cars[%d]: %w", i, err)
+		}
+		// Seeding has to be repeatable, so engines are fixtures of their own with fixed IDs
+		if f.Cars[i].Engine != nil {
+			return fmt.Errorf("cars[%d]: give the engine by engine_id, inline engines are not supported", i)
+		}
+	}
+	for i := range f.Users {
+		if err := fixtureValidator.Struct(&f.Users[i]); err != nil {
+			return fmt.Errorf("users[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Seed upserts the fixtures in one transaction. Records are matched by id
+// (engines, cars) or email (users), soft deleted rows included: missing ones are
+// created, the others updated to the fixture and restored from the trash, so
+// seeding twice changes nothing.
+func Seed(ctx context.Context, db *gorm.DB, fixtures *Fixtures) ([]SeedResult, error) {
+	results := []SeedResult{{Kind: "engines"}, {Kind: "cars"}, {Kind: "users"}}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, f := range fixtures.Engines {
+			engine := models.Engine{
+				EngineID:      f.EngineID,
+				Displacement:  f.Displacement,
+				NoOfCylinders: f.NoOfCylinders,
+				CarRange:      f.CarRange,
+			}
+			same := func(e *models.Engine) bool {
+				return e.Displacement == f.Displacement && e.NoOfCylinders == f.NoOfCylinders && e.CarRange == f.CarRange
+			}
+			fields := map[string]interface{}{
+				"displacement":    f.Displacement,
+				"no_of_cylinders": f.NoOfCylinders,
+				"car_range":       f.CarRange,
+				"version":         gorm.Expr("version + 1"),
+			}
+			if err := upsert(tx, "engine_id", f.EngineID, &engine, same, fields, &results[0]); err != nil {
+				return fmt.Errorf("engine %s: %w", f.EngineID, err)
+			}
+		}
+
+		for _, f := range fixtures.Cars {
+			car := models.Car{
+				ID:       f.ID,
+				Name:     f.Name,
+				Year:     f.Year,
+				Brand:    f.Brand,
+				FuelType: f.FuelType,
+				Price:    f.Price,
+				EngineID: f.EngineID,
+			}
+			same := func(c *models.Car) bool {
+				return c.Name == f.Name && c.Year == f.Year && c.Brand == f.Brand &&
+					c.FuelType == f.FuelType && c.Price == f.Price && c.EngineID == f.EngineID
+			}
+			fields := map[string]interface{}{
+				"name":      f.Name,
+				"year":      f.Year,
+				"brand":     f.Brand,
+				"fuel_type": f.FuelType,
+				"price":     f.Price,
+				"engine_id": f.EngineID,
+				"version":   gorm.Expr("version + 1"),
+			}
+			if err := upsert(tx, "id", f.ID, &car, same, fields, &results[1]); err != nil {
+				return fmt.Errorf("car %s: %w", f.ID, err)
+			}
+		}
+
+		for _, f := range fixtures.Users {
+			// Stored like Register stores them, so the fixture accounts can log in
+			user := models.User{Email: strings.ToLower(strings.TrimSpace(f.Email)), Name: f.Name, Phone: f.Phone, Role: f.Role}
+			if user.Role == "" {
+				user.Role = models.RoleViewer
+			}
+			same := func(u *models.User) bool {
+				return u.Name == user.Name && u.Phone == user.Phone && u.Role == user.Role && u.CheckPassword(f.Password)
+			}
+			if err := user.SetPassword(f.Password); err != nil {
+				return err
+			}
+			fields := map[string]interface{}{
+				"name":          user.Name,
+				"phone":         user.Phone,
+				"role":          user.Role,
+				"password_hash": user.PasswordHash,
+			}
+			if err := upsert(tx, "email", user.Email, &user, same, fields, &results[2]); err != nil {
+				return fmt.Errorf("user %s: %w", user.Email, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// upsert creates record unless a row with the same key exists. An existing row
+// is updated with fields unless same reports it already matches the fixture, a
+// soft deleted one is updated and restored. The outcome is counted in result.
+func upsert[T any](tx *gorm.DB, key string, value interface{}, record *T, same func(*T) bool, fields map[string]interface{}, result *SeedResult) error {
+	var existing T
+	err := tx.Where(key+" = ?", value).Take(&existing).Error
+	trashed := false
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = tx.Unscoped().Where(key+" = ?", value).Take(&existing).Error
+		trashed = err == nil
+	}
+	fields["deleted_at"] = nil
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := tx.Omit(clause.Associations).Create(record).Error; err != nil {
+			return err
+		}
+		result.Created++
+	case err != nil:
+		return err
+	case !trashed && same(&existing):
+		result.Skipped++
+	default:
+		if err := tx.Unscoped().Model(&existing).Updates(fields).Error; err != nil {
+			return err
+		}
+		if trashed {
+			result.Restored++
+		} else {
+			result.Updated++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// maxStatementLength caps the SQL recorded on a span
+const maxStatementLength = 2048
+
+const (
+	telemetryStartKey   = "telemetry:start"
+	telemetryContextKey = "telemetry:context"
+	telemetrySpanKey    = "telemetry:span"
+)
+
+// dbAffectedRowsKey records the rows an INSERT, UPDATE or DELETE changed, the
+// semantic conventions have no attribute for it
+var dbAffectedRowsKey = attribute.Key("gorm.rows_affected")
+
+type untracedKey struct{}
+
+// WithoutTracing marks ctx so statements run with it start no spans, their
+// durations are still recorded. The readiness checks use it, they run every
+// few seconds and would bury the API traces.
+func WithoutTracing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, untracedKey{}, true)
+}
+
+func untraced(ctx context.Context) bool {
+	skip, _ := ctx.Value(untracedKey{}).(bool)
+	return skip
+}
+
+var queryDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Histogram of SQL statement durations by table and operation",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms to ~4s
+	},
+	[]string{"table", "operation"},
+)
+
+func init() {
+	prometheus.MustRegister(queryDuration)
+}
+
+// TelemetryPlugin traces every GORM statement as a child span of the context
+// passed with WithContext and records its duration in db_query_duration_seconds.
+// Spans carry the SQL with its placeholders, the bound values are left out.
+type TelemetryPlugin struct{}
+
+func (TelemetryPlugin) Name() string {
+	return "telemetry"
+}
+
+func (p TelemetryPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("telemetry:before_create", p.before("create")),
+		cb.Create().After("gorm:after_create").Register("telemetry:after_create", p.after),
+		cb.Query().Before("gorm:query").Register("telemetry:before_query", p.before("query")),
+		cb.Query().After("gorm:after_query").Register("telemetry:after_query", p.after),
+		cb.Update().Before("gorm:update").Register("telemetry:before_update", p.before("update")),
+		cb.Update().After("gorm:after_update").Register("telemetry:after_update", p.after),
+		cb.Delete().Before("gorm:delete").Register("telemetry:before_delete", p.before("delete")),
+		cb.Delete().After("gorm:after_delete").Register("telemetry:after_delete", p.after),
+		cb.Row().Before("gorm:row").Register("telemetry:before_row", p.before("row")),
+		cb.Row().After("gorm:row").Register("telemetry:after_row", p.after),
+		cb.Raw().Before("gorm:raw").Register("telemetry:before_raw", p.before("raw")),
+		cb.Raw().After("gorm:raw").Register("telemetry:after_raw", p.after),
+	)
+}
+
+func (TelemetryPlugin) before(callback string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(telemetryContextKey, db.Statement.Context)
+		db.InstanceSet(telemetryStartKey, time.Now())
+		if untraced(db.Statement.Context) {
+			return
+		}
+
+		// The span is renamed once the statement is built and its operation known
+		var span oteltrace.Span
+		db.Statement.Context, span = otel.Tracer("GORM").Start(db.Statement.Context, "gorm."+callback, oteltrace.WithSpanKind(oteltrace.SpanKindClient))
+		db.InstanceSet(telemetrySpanKey, span)
+	}
+}
+
+func (TelemetryPlugin) after(db *gorm.DB) {
+	// A noop span for untraced statements, whose context may carry the caller's span
+	span := oteltrace.SpanFromContext(context.Background())
+	if started, _ := db.InstanceGet(telemetrySpanKey); started != nil {
+		span = started.(oteltrace.Span)
+		db.InstanceSet(telemetrySpanKey, nil)
+	}
+	defer span.End()
+	// Later statements of the same instance are children of the caller again
+	if ctx, ok := db.InstanceGet(telemetryContextKey); ok {
+		db.Statement.Context = ctx.(context.Context)
+	}
+
+	sql := db.Statement.SQL.String()
+	operation := statementOperation(sql)
+	table := db.Statement.Table
+	if table == "" {
+		table = "unknown"
+	}
+
+	if start, ok := db.InstanceGet(telemetryStartKey); ok {
+		queryDuration.WithLabelValues(table, operation).Observe(time.Since(start.(time.Time)).Seconds())
+	}
+
+	if !span.IsRecording() {
+		return
+	}
+	// RowsAffected counts the rows read by a query, but the rows changed by a
+	// write, which only returns them with RETURNING
+	rows := int(db.Statement.RowsAffected)
+	if returnsRows(operation, sql) {
+		span.SetAttributes(semconv.DBResponseReturnedRows(rows))
+	}
+	if operation != "SELECT" {
+		span.SetAttributes(dbAffectedRowsKey.Int(rows))
+	}
+
+	if len(sql) > maxStatementLength {
+		sql = sql[:maxStatementLength]
+	}
+	span.SetName(operation + " " + table)
+	span.SetAttributes(
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBOperationName(operation),
+		semconv.DBCollectionName(table),
+		semconv.DBQueryText(sql),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
+
+// returnsRows reports whether a statement answers with rows, a SELECT or a write
+// with a RETURNING clause
+func returnsRows(operation, sql string) bool {
+	if operation == "SELECT" || operation == "WITH" {
+		return true
+	}
+	return strings.Contains(strings.ToUpper(sql), " RETURNING ")
+}
+
+// statementOperation returns the SQL verb of a statement, e.g. SELECT
+func statementOperation(sql string) string {
+	verb, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	if verb == "" {
+		return "UNKNOWN"
+	}
+	return strings.ToUpper(verb)
+}
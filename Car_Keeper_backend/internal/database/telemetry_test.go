@@ -0,0 +1,104 @@
+package database
+
+import (
+	"Car_Keeper/internal/models"
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newTracedDryRunDB returns a DryRun database with the TelemetryPlugin whose
+// spans end up in the returned recorder
+func newTracedDryRunDB(t *testing.T) (*gorm.DB, *sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(TelemetryPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	return db, provider, recorder
+}
+
+func TestTelemetryPluginSkipsUntracedStatements(t *testing.T) {
+	db, provider, recorder := newTracedDryRunDB(t)
+
+	tests := []struct {
+		name      string
+		ctx       func(context.Context) context.Context
+		wantSpans int
+	}{
+		{name: "traced", ctx: func(ctx context.Context) context.Context { return ctx }, wantSpans: 2},
+		{name: "untraced", ctx: WithoutTracing, wantSpans: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(recorder.Ended())
+			ctx, parent := provider.Tracer("test").Start(context.Background(), "caller")
+			var rows []map[string]any
+			db.WithContext(tt.ctx(ctx)).Table("cars").Find(&rows)
+			if !parent.IsRecording() {
+				t.Fatal("the statement ended the caller's span")
+			}
+			parent.End()
+
+			if got := len(recorder.Ended()) - before; got != tt.wantSpans {
+				t.Errorf("%d spans ended, want %d", got, tt.wantSpans)
+			}
+		})
+	}
+}
+
+func TestTelemetryPluginRowAttributes(t *testing.T) {
+	db, _, recorder := newTracedDryRunDB(t)
+	engine := &models.Engine{Displacement: 2000, NoOfCylinders: 4, CarRange: 600}
+
+	tests := []struct {
+		name         string
+		run          func(*gorm.DB)
+		wantReturned bool
+		wantAffected bool
+	}{
+		{name: "select", run: func(db *gorm.DB) { db.Find(&[]models.Engine{}) }, wantReturned: true},
+		{name: "insert", run: func(db *gorm.DB) { db.Create(engine) }, wantAffected: true},
+		{name: "update returning", run: func(db *gorm.DB) {
+			db.Exec("UPDATE engines SET car_range = 100 WHERE car_range < 100 RETURNING engine_id")
+		}, wantReturned: true, wantAffected: true},
+		{name: "update", run: func(db *gorm.DB) { db.Model(&models.Engine{}).Where("car_range < ?", 100).Update("car_range", 100) }, wantAffected: true},
+		{name: "delete", run: func(db *gorm.DB) { db.Where("car_range < ?", 100).Delete(&models.Engine{}) }, wantAffected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(recorder.Ended())
+			tt.run(db.Session(&gorm.Session{}))
+			spans := recorder.Ended()[before:]
+			if len(spans) != 1 {
+				t.Fatalf("%d spans, want 1", len(spans))
+			}
+
+			keys := map[attribute.Key]bool{}
+			for _, kv := range spans[0].Attributes() {
+				keys[kv.Key] = true
+			}
+			if keys["db.response.returned_rows"] != tt.wantReturned {
+				t.Errorf("returned rows recorded = %v, want %v", !tt.wantReturned, tt.wantReturned)
+			}
+			if keys[dbAffectedRowsKey] != tt.wantAffected {
+				t.Errorf("affected rows recorded = %v, want %v", !tt.wantAffected, tt.wantAffected)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package dto
+
+import (
+	"Car_Keeper/internal/models"
+	"time"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=cars:read cars:write engines:read engines:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse is the only place the plain text key is ever returned
+type CreateAPIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,7 @@
+package dto
+
+// ExportOptions are the query parameters of the export endpoints, next to the listing filters
+type ExportOptions struct {
+	Format        string `form:"format" binding:"omitempty,oneof=csv ndjson xlsx"`
+	FlattenEngine bool   `form:"flatten_engine"` // add the engine spec columns to car rows
+}
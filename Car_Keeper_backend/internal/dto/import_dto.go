@@ -0,0 +1,50 @@
+package dto
+
+import "Car_Keeper/internal/models"
+
+const (
+	ImportModeAtomic     = "atomic"      // nothing is written unless every row is valid
+	ImportModeBestEffort = "best_effort" // valid rows are written, invalid ones reported
+)
+
+// ImportOptions are the query parameters of POST /cars/import
+type ImportOptions struct {
+	Mode   string `form:"mode" binding:"omitempty,oneof=atomic best_effort"`
+	DryRun bool   `form:"dry_run"`
+}
+
+// CarImportRow is one car of an import file. The engine is given either by
+// engine_id or by its spec, which unlike in a single create must match an
+// existing engine.
+type CarImportRow struct {
+	Row int `json:"-"` // line of the row in the uploaded file
+
+	models.CarRequest
+}
+
+// CarImport is a parsed import file: the rows that passed validation and the
+// errors of the ones that did not
+type CarImport struct {
+	ImportOptions
+	Total  int
+	Rows   []CarImportRow
+	Errors []ImportRowError
+}
+
+// ImportRowError is one problem of one row
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult reports what an import did, or would do for a dry run
+type ImportResult struct {
+	Mode     string           `json:"mode"`
+	DryRun   bool             `json:"dry_run"`
+	Total    int              `json:"total"`
+	Valid    int              `json:"valid"`
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors"`
+}
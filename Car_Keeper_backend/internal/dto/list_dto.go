@@ -0,0 +1,22 @@
+package dto
+
+type ListResponse struct {
+	Data  interface{} `json:"data"`
+	Meta  ListMeta    `json:"meta"`
+	Links ListLinks   `json:"links"`
+}
+
+type ListMeta struct {
+	Total      int64  `json:"total"`
+	Count      int    `json:"count"`
+	Limit      int    `json:"limit"`
+	Offset     *int   `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+type ListLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
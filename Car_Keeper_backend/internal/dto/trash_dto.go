@@ -0,0 +1,18 @@
+package dto
+
+import (
+	"Car_Keeper/internal/models"
+	"time"
+)
+
+// TrashedCar is a soft-deleted car together with the time it was deleted
+type TrashedCar struct {
+	models.Car
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// TrashedEngine is a soft-deleted engine together with the time it was deleted
+type TrashedEngine struct {
+	models.Engine
+	DeletedAt time.Time `json:"deleted_at"`
+}
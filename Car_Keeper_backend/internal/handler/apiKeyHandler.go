@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"Car_Keeper/internal/dto"
+	"Car_Keeper/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+)
+
+type APIKeyHandler struct {
+	service service.APIKeyService
+}
+
+func NewAPIKeyHandler(service service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	tracer := otel.Tracer("APIKeyHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "CreateAPIKey-Handler")
+	defer span.End()
+
+	var req dto.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(ctx, c.GetUint("userID"), &req)
+	if err != nil {
+		respondError(c, "Failed to create API key", err)
+		return
+	}
+	c.JSON(201, key)
+}
+
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	tracer := otel.Tracer("APIKeyHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "ListAPIKeys-Handler")
+	defer span.End()
+
+	keys, err := h.service.ListAPIKeys(ctx)
+	if err != nil {
+		respondError(c, "Failed to list API keys", err)
+		return
+	}
+	c.JSON(200, keys)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	tracer := otel.Tracer("APIKeyHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "RevokeAPIKey-Handler")
+	defer span.End()
+
+	if err := h.service.RevokeAPIKey(ctx, c.Param("keyid")); err != nil {
+		respondError(c, "Failed to revoke API key", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "API key revoked successfully"})
+}
@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/dto"
 	"Car_Keeper/internal/models"
 	"Car_Keeper/internal/service"
+	"Car_Keeper/pkg/response"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel"
@@ -25,24 +29,69 @@ func (h *CarHandler) GetCarByID(c *gin.Context) {
 	carID := c.Param("carid")
 	car, err := h.service.GetCarByID(ctx, carID)
 	if err != nil {
-		c.JSON(404, gin.H{"message": "Car not found", "error": err.Error()})
+		respondError(c, "Failed to get car", err)
+		return
+	}
+	if notModified(c, car.Version) {
 		return
 	}
 	c.JSON(200, car)
 }
 
-func (h *CarHandler) GetCarByBrand(c *gin.Context) {
+// List cars with filters, sorting and offset or cursor pagination
+func (h *CarHandler) ListCars(c *gin.Context) {
 	tracer := otel.Tracer("CarHandler")
-	ctx, span := tracer.Start(c.Request.Context(), "GetCarByBrand-Handler")
+	ctx, span := tracer.Start(c.Request.Context(), "ListCars-Handler")
 	defer span.End()
 
-	brand := c.Query("brand")
-	cars, err := h.service.GetCarByBrand(ctx, brand)
+	var query models.CarQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	page, err := h.service.ListCars(ctx, &query)
 	if err != nil {
-		c.JSON(404, gin.H{"message": "Cars not found", "error": err.Error()})
+		respondError(c, "Failed to list cars", err)
 		return
 	}
-	c.JSON(200, cars)
+	c.JSON(200, newListResponse(c, query.PageRequest, page))
+}
+
+// Export the cars matching the listing filters as CSV, NDJSON or XLSX
+func (h *CarHandler) ExportCars(c *gin.Context) {
+	tracer := otel.Tracer("CarHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "ExportCars-Handler")
+	defer span.End()
+
+	if rejectExportPaging(c) {
+		return
+	}
+	var query models.CarQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	var opts dto.ExportOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	columns := []string{"id", "name", "year", "brand", "fuel_type", "price", "engine_id", "version", "created_at", "updated_at"}
+	if opts.FlattenEngine {
+		columns = append(columns, "engine_displacement", "engine_no_of_cylinders", "engine_car_range")
+	}
+
+	exp := newExporter(c, opts.Format, "cars", columns)
+	err := h.service.ExportCars(ctx, &query, func(car *models.Car) error {
+		values := []any{car.ID, car.Name, car.Year, car.Brand, car.FuelType, car.Price, car.EngineID, car.Version, car.CreatedAt, car.UpdatedAt}
+		if opts.FlattenEngine {
+			values = append(values, car.Engine.Displacement, car.Engine.NoOfCylinders, car.Engine.CarRange)
+		}
+		return exp.Write(values)
+	})
+	exp.Finish(err)
 }
 
 func (h *CarHandler) CreateCar(c *gin.Context) {
@@ -53,15 +102,60 @@ func (h *CarHandler) CreateCar(c *gin.Context) {
 	var carReq models.CarRequest
 	// Bind JSON request to struct
 	if err := c.ShouldBindJSON(&carReq); err != nil {
-		c.JSON(400, gin.H{"message": "Invalid request", "error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 	// Call service to create car
-	if err := h.service.CreateCar(ctx, &carReq); err != nil {
-		c.JSON(500, gin.H{"message": "Failed to create car", "error": err.Error()})
+	car, err := h.service.CreateCar(ctx, &carReq)
+	if err != nil {
+		respondError(c, "Failed to create car", err)
+		return
+	}
+	c.Header("Location", "/api/v1/cars/"+car.ID.String())
+	c.Header("ETag", etag(car.Version))
+	c.JSON(201, car)
+}
+
+// Import cars from a CSV or NDJSON file, see bindCarImport for the format
+func (h *CarHandler) ImportCars(c *gin.Context) {
+	tracer := otel.Tracer("CarHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "ImportCars-Handler")
+	defer span.End()
+
+	var opts dto.ImportOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	carImport, ok := bindCarImport(c, opts)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.ImportCars(ctx, carImport)
+	if err != nil {
+		respondError(c, "Failed to import cars", err)
 		return
 	}
-	c.JSON(201, gin.H{"message": "Car created successfully"})
+
+	// An atomic import with invalid rows wrote nothing, answer with the row errors
+	if result.Mode == dto.ImportModeAtomic && result.Failed > 0 && !result.DryRun {
+		problem := response.Problem{
+			Status: 422,
+			Detail: fmt.Sprintf("%d of %d rows are invalid, nothing was imported", result.Failed, result.Total),
+		}
+		for _, e := range result.Errors {
+			field := fmt.Sprintf("rows[%d]", e.Row)
+			if e.Field != "" {
+				field += "." + e.Field
+			}
+			problem.Errors = append(problem.Errors, response.FieldError{Field: field, Message: e.Message})
+		}
+		response.WriteProblem(c, problem)
+		return
+	}
+	c.JSON(200, result)
 }
 
 func (h *CarHandler) UpdateCar(c *gin.Context) {
@@ -70,18 +164,63 @@ func (h *CarHandler) UpdateCar(c *gin.Context) {
 	defer span.End()
 
 	carId := c.Param("carid")
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
 	var carReq models.CarRequest
 	// Bind JSON request to struct
 	if err := c.ShouldBindJSON(&carReq); err != nil {
-		c.JSON(400, gin.H{"message": "Invalid request", "error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 	// Call service to update car
-	if err := h.service.UpdateCar(ctx, carId, &carReq); err != nil {
-		c.JSON(500, gin.H{"message": "Failed to update car", "error": err.Error()})
+	car, err := h.service.UpdateCar(ctx, carId, version, &carReq)
+	if err != nil {
+		respondError(c, "Failed to update car", err)
+		return
+	}
+	c.Header("ETag", etag(car.Version))
+	c.JSON(200, car)
+}
+
+// Partially update a car with a JSON Merge Patch or JSON Patch document
+func (h *CarHandler) PatchCar(c *gin.Context) {
+	tracer := otel.Tracer("CarHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "PatchCar-Handler")
+	defer span.End()
+
+	carID := c.Param("carid")
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	car, err := h.service.GetCarByID(ctx, carID)
+	if err != nil {
+		respondError(c, "Failed to get car", err)
 		return
 	}
-	c.JSON(200, gin.H{"message": "Car updated successfully"})
+	if version != 0 && version != car.Version {
+		respondError(c, "Failed to update car", apperrors.VersionMismatch("car"))
+		return
+	}
+
+	current := car.ToRequest()
+	var carReq models.CarRequest
+	if !bindPatch(c, &current, &carReq) {
+		return
+	}
+
+	// The write is conditioned on the version the patch was applied to
+	updated, err := h.service.UpdateCar(ctx, carID, car.Version, &carReq)
+	if err != nil {
+		respondError(c, "Failed to update car", err)
+		return
+	}
+	c.Header("ETag", etag(updated.Version))
+	c.JSON(200, updated)
 }
 
 func (h *CarHandler) DeleteCar(c *gin.Context) {
@@ -90,8 +229,13 @@ func (h *CarHandler) DeleteCar(c *gin.Context) {
 	defer span.End()
 
 	carID := c.Param("carid")
-	if err := h.service.DeleteCar(ctx, carID); err != nil {
-		c.JSON(500, gin.H{"message": "Failed to delete car", "error": err.Error()})
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteCar(ctx, carID, version); err != nil {
+		respondError(c, "Failed to delete car", err)
 		return
 	}
 	c.JSON(200, gin.H{"message": "Car deleted successfully"})
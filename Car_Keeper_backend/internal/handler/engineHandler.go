@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/dto"
 	"Car_Keeper/internal/models"
 	"Car_Keeper/internal/service"
+	"Car_Keeper/pkg/response"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 )
 
@@ -23,19 +27,70 @@ func (h *EngineHandler) GetEngineByID(c *gin.Context) {
 
 	engineID := c.Param("engineid")
 	if engineID == "" {
-		c.JSON(400, gin.H{"message": "Engine ID is required"})
+		response.Error(c, 400, "Engine ID is required")
 		return
 	}
 
 	// Call service to get engine by ID
 	engine, err := h.service.GetEngineByID(ctx, engineID)
 	if err != nil {
-		c.JSON(404, gin.H{"message": "Engine not found", "error": err.Error()})
+		respondError(c, "Failed to get engine", err)
+		return
+	}
+	if notModified(c, engine.Version) {
 		return
 	}
 	c.JSON(200, engine)
 }
 
+// List engines with their car counts, filtered and paginated
+func (h *EngineHandler) ListEngines(c *gin.Context) {
+	trace := otel.Tracer("EngineHandler")
+	ctx, span := trace.Start(c.Request.Context(), "ListEngines-Handler")
+	defer span.End()
+
+	var query models.EngineQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	page, err := h.service.ListEngines(ctx, &query)
+	if err != nil {
+		respondError(c, "Failed to list engines", err)
+		return
+	}
+	c.JSON(200, newListResponse(c, query.PageRequest, page))
+}
+
+// Export the engines matching the listing filters as CSV, NDJSON or XLSX
+func (h *EngineHandler) ExportEngines(c *gin.Context) {
+	trace := otel.Tracer("EngineHandler")
+	ctx, span := trace.Start(c.Request.Context(), "ExportEngines-Handler")
+	defer span.End()
+
+	if rejectExportPaging(c) {
+		return
+	}
+	var query models.EngineQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	var opts dto.ExportOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	columns := []string{"engine_id", "displacement", "no_of_cylinders", "car_range", "car_count", "version", "created_at", "updated_at"}
+	exp := newExporter(c, opts.Format, "engines", columns)
+	err := h.service.ExportEngines(ctx, &query, func(e *models.EngineSummary) error {
+		return exp.Write([]any{e.EngineID, e.Displacement, e.NoOfCylinders, e.CarRange, e.CarCount, e.Version, e.CreatedAt, e.UpdatedAt})
+	})
+	exp.Finish(err)
+}
+
 func (h *EngineHandler) CreateEngine(c *gin.Context) {
 	trace := otel.Tracer("EngineHandler")
 	ctx, span := trace.Start(c.Request.Context(), "CreateEngine-Handler")
@@ -43,14 +98,14 @@ func (h *EngineHandler) CreateEngine(c *gin.Context) {
 
 	var engineReq models.EngineRequest
 	if err := c.ShouldBindJSON(&engineReq); err != nil {
-		c.JSON(400, gin.H{"message": "Invalid request", "error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	// Call service to create engine
 	engine, err := h.service.CreateEngine(ctx, &engineReq)
 	if err != nil {
-		c.JSON(500, gin.H{"message": "Failed to create engine", "error": err.Error()})
+		respondError(c, "Failed to create engine", err)
 		return
 	}
 	c.JSON(201, engine)
@@ -63,25 +118,68 @@ func (h *EngineHandler) UpdateEngine(c *gin.Context) {
 
 	engineID := c.Param("engineid")
 	if engineID == "" {
-		c.JSON(400, gin.H{"message": "Engine ID is required"})
+		response.Error(c, 400, "Engine ID is required")
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
 	var engineReq models.EngineRequest
 	if err := c.ShouldBindJSON(&engineReq); err != nil {
-		c.JSON(400, gin.H{"message": "Invalid request", "error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	// Call service to update engine
-	engine, err := h.service.UpdateEngine(ctx, engineID, &engineReq)
+	engine, err := h.service.UpdateEngine(ctx, engineID, version, &engineReq)
 	if err != nil {
-		c.JSON(500, gin.H{"message": "Failed to update engine", "error": err.Error()})
+		respondError(c, "Failed to update engine", err)
 		return
 	}
+	c.Header("ETag", etag(engine.Version))
 	c.JSON(200, engine)
 }
 
+// Partially update an engine with a JSON Merge Patch or JSON Patch document
+func (h *EngineHandler) PatchEngine(c *gin.Context) {
+	trace := otel.Tracer("EngineHandler")
+	ctx, span := trace.Start(c.Request.Context(), "PatchEngine-Handler")
+	defer span.End()
+
+	engineID := c.Param("engineid")
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	engine, err := h.service.GetEngineByID(ctx, engineID)
+	if err != nil {
+		respondError(c, "Failed to get engine", err)
+		return
+	}
+	if version != 0 && version != engine.Version {
+		respondError(c, "Failed to update engine", apperrors.VersionMismatch("engine"))
+		return
+	}
+
+	current := engine.ToRequest()
+	var engineReq models.EngineRequest
+	if !bindPatch(c, &current, &engineReq) {
+		return
+	}
+
+	// The write is conditioned on the version the patch was applied to
+	updated, err := h.service.UpdateEngine(ctx, engineID, engine.Version, &engineReq)
+	if err != nil {
+		respondError(c, "Failed to update engine", err)
+		return
+	}
+	c.Header("ETag", etag(updated.Version))
+	c.JSON(200, updated)
+}
+
 func (h *EngineHandler) DeleteEngine(c *gin.Context) {
 	trace := otel.Tracer("EngineHandler")
 	ctx, span := trace.Start(c.Request.Context(), "DeleteEngine-Handler")
@@ -89,14 +187,33 @@ func (h *EngineHandler) DeleteEngine(c *gin.Context) {
 
 	engineID := c.Param("engineid")
 	if engineID == "" {
-		c.JSON(400, gin.H{"message": "Engine ID is required"})
+		response.Error(c, 400, "Engine ID is required")
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	// Cars still using the engine block the delete unless a strategy says what happens to them
+	var opts models.EngineDeleteOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
 	// Call service to delete engine
-	if err := h.service.DeleteEngine(ctx, engineID); err != nil {
-		c.JSON(500, gin.H{"message": "Failed to delete engine", "error": err.Error()})
+	carIDs, err := h.service.DeleteEngine(ctx, engineID, version, &opts)
+	if err != nil {
+		respondError(c, "Failed to delete engine", err)
 		return
 	}
-	c.JSON(200, gin.H{"message": "Engine deleted successfully"})
+	if carIDs == nil {
+		carIDs = []uuid.UUID{}
+	}
+	c.JSON(200, gin.H{
+		"message":          "Engine deleted successfully",
+		"strategy":         opts.Strategy,
+		"affected_car_ids": carIDs,
+	})
 }
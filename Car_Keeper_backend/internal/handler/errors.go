@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/pkg/response"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report json/form names instead of Go field names in validation errors
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+					return name
+				}
+			}
+			return f.Name
+		})
+	}
+}
+
+// errorStatus maps a domain error kind to its HTTP status
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, apperrors.ErrInvalidID), errors.Is(err, apperrors.ErrInvalidQuery):
+		return 400
+	case errors.Is(err, apperrors.ErrUnauthorized):
+		return 401
+	case errors.Is(err, apperrors.ErrForbidden):
+		return 403
+	case errors.Is(err, apperrors.ErrNotFound):
+		return 404
+	case errors.Is(err, apperrors.ErrConflict):
+		return 409
+	case errors.Is(err, apperrors.ErrPreconditionFailed):
+		return 412
+	case errors.Is(err, apperrors.ErrValidation), errors.Is(err, apperrors.ErrForeignKeyViolation):
+		return 422
+	default:
+		return 500
+	}
+}
+
+// respondError writes the problem+json response for a failed service call.
+// Domain errors expose their client message and field; anything else is logged
+// and answered with message only, so database errors never reach the client.
+func respondError(c *gin.Context, message string, err error) {
+	problem := response.Problem{Status: errorStatus(err), Detail: message}
+
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) {
+		problem.Detail = appErr.Message
+		if appErr.Field != "" {
+			problem.Errors = []response.FieldError{{Field: appErr.Field, Message: appErr.Message}}
+		}
+	}
+	if problem.Status == 500 {
+		log.Printf("[ERROR] %s %s: %s: %v", c.Request.Method, c.Request.URL.Path, message, err)
+	}
+
+	response.WriteProblem(c, problem)
+}
+
+// logClientError logs why a request was rejected when the client only gets a
+// fixed message, so decoder and library errors stay out of the response
+func logClientError(c *gin.Context, message string, err error) {
+	log.Printf("[INFO] %s %s: %s: %v", c.Request.Method, c.Request.URL.Path, message, err)
+}
+
+// respondBindError answers a failed ShouldBindJSON/ShouldBindQuery: 422 with one
+// entry per invalid field, or 400 when the body could not be decoded at all.
+func respondBindError(c *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		problem := response.Problem{Status: 422, Detail: "The request has invalid fields"}
+		for _, fe := range validationErrs {
+			problem.Errors = append(problem.Errors, response.FieldError{
+				Field:   fieldPath(fe),
+				Message: validationMessage(fe),
+			})
+		}
+		response.WriteProblem(c, problem)
+		return
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		response.WriteProblem(c, response.Problem{
+			Status: 422,
+			Detail: "The request has invalid fields",
+			Errors: []response.FieldError{{Field: typeErr.Field, Message: "must be of type " + typeErr.Type.String()}},
+		})
+		return
+	}
+
+	response.WriteProblem(c, response.Problem{Status: 400, Detail: "The request body or query could not be parsed"})
+}
+
+// fieldPath drops the top level struct name, "CarRequest.engine.displacement" becomes "engine.displacement"
+func fieldPath(fe validator.FieldError) string {
+	if _, path, ok := strings.Cut(fe.Namespace(), "."); ok {
+		return path
+	}
+	return fe.Field()
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return fmt.Sprintf("is required when %s is not set", strings.ToLower(fe.Param()))
+	case "excluded_with":
+		return "cannot be set together with " + strings.ToLower(fe.Param())
+	case "required_if":
+		field, value, _ := strings.Cut(fe.Param(), " ")
+		return fmt.Sprintf("is required when %s is %s", strings.ToLower(field), value)
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters long", fe.Param())
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "email":
+		return "must be a valid email address"
+	case "uuid":
+		return "must be a valid UUID"
+	case "numeric":
+		return "must be numeric"
+	default:
+		return fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
+}
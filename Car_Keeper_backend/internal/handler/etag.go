@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"Car_Keeper/pkg/response"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag formats a resource version as a strong entity tag
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// notModified sets the ETag header for version and answers 304 when the
+// If-None-Match header already holds it. Weak tags match as well (RFC 9110 13.1.2).
+func notModified(c *gin.Context, version int64) bool {
+	tag := etag(version)
+	c.Header("ETag", tag)
+
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			c.AbortWithStatus(304)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch returns the version a write is conditioned on. "*" returns 0, which
+// matches any version. A missing header is answered with 428 and a tag that is
+// not one of our strong version tags with 412, both returning false.
+func ifMatch(c *gin.Context) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		response.Error(c, 428, "This request requires an If-Match header with the ETag of the resource")
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	version, parseErr := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || parseErr != nil || version <= 0 {
+		response.Error(c, 412, "If-Match does not match the current ETag of the resource")
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newHeaderContext(header, value string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if value != "" {
+		c.Request.Header.Set(header, value)
+	}
+	return c, w
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{name: "no header", header: "", want: false},
+		{name: "same version", header: `"3"`, want: true},
+		{name: "weak tag matches", header: `W/"3"`, want: true},
+		{name: "one of a list", header: `"1", W/"3" ,"5"`, want: true},
+		{name: "any", header: "*", want: true},
+		{name: "other version", header: `"2"`, want: false},
+		{name: "unquoted", header: "3", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newHeaderContext("If-None-Match", tt.header)
+			if got := notModified(c, 3); got != tt.want {
+				t.Errorf("notModified = %v, want %v", got, tt.want)
+			}
+			if got := w.Header().Get("ETag"); got != `"3"` {
+				t.Errorf("ETag = %q, want %q", got, `"3"`)
+			}
+			if tt.want && c.Writer.Status() != http.StatusNotModified {
+				t.Errorf("status = %d, want 304", c.Writer.Status())
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantVersion int64
+		wantOK      bool
+		wantStatus  int
+	}{
+		{name: "strong tag", header: `"7"`, wantVersion: 7, wantOK: true},
+		{name: "padded", header: `  "7" `, wantVersion: 7, wantOK: true},
+		{name: "any version", header: "*", wantVersion: 0, wantOK: true},
+		{name: "missing", header: "", wantStatus: http.StatusPreconditionRequired},
+		{name: "weak tag", header: `W/"7"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "unquoted", header: "7", wantStatus: http.StatusPreconditionFailed},
+		{name: "not a version", header: `"abc"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "zero", header: `"0"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "list", header: `"7", "8"`, wantStatus: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newHeaderContext("If-Match", tt.header)
+			version, ok := ifMatch(c)
+			if ok != tt.wantOK || version != tt.wantVersion {
+				t.Errorf("ifMatch = %d, %v, want %d, %v", version, ok, tt.wantVersion, tt.wantOK)
+			}
+			if !tt.wantOK && w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
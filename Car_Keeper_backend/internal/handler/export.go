@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"Car_Keeper/pkg/response"
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// exportFlushEvery is the number of rows after which a streaming export is flushed to the client
+const exportFlushEvery = 500
+
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// exportPagingParams are the listing parameters an export refuses, it always
+// streams the full filtered set
+var exportPagingParams = []string{"limit", "offset", "cursor"}
+
+// rejectExportPaging answers 400 and returns true when the query asks for a page
+func rejectExportPaging(c *gin.Context) bool {
+	query := c.Request.URL.Query()
+	for _, param := range exportPagingParams {
+		if query.Has(param) {
+			response.Error(c, 400, "Exports stream every matching row, "+param+" is not supported")
+			return true
+		}
+	}
+	return false
+}
+
+// exportWriter encodes rows of cells in one export format
+type exportWriter interface {
+	WriteRow(values []any) error
+	Flush() error
+	Close() error
+}
+
+// exporter streams rows to the client. The response is only started with the
+// first row, so errors raised before it can still be answered with a problem.
+type exporter struct {
+	c       *gin.Context
+	format  string
+	name    string
+	columns []string
+	writer  exportWriter
+	rows    int
+}
+
+func newExporter(c *gin.Context, format, name string, columns []string) *exporter {
+	if format == "" {
+		format = "csv"
+	}
+	return &exporter{c: c, format: format, name: name, columns: columns}
+}
+
+func (e *exporter) Write(values []any) error {
+	if e.writer == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	if err := e.writer.WriteRow(values); err != nil {
+		return err
+	}
+	if e.rows++; e.rows%exportFlushEvery == 0 {
+		if err := e.writer.Flush(); err != nil {
+			return err
+		}
+		e.c.Writer.Flush()
+	}
+	return nil
+}
+
+// Finish completes the export. err is the error that ended the stream, if any.
+func (e *exporter) Finish(err error) {
+	if err != nil && e.writer == nil {
+		respondError(e.c, "Failed to export "+e.name, err)
+		return
+	}
+	if err != nil {
+		// The status line is gone already, all we can do is cut the file short
+		log.Printf("[ERROR] %s %s: export of %s failed after %d rows: %v", e.c.Request.Method, e.c.Request.URL.Path, e.name, e.rows, err)
+		e.c.Abort()
+		return
+	}
+	if e.writer == nil {
+		if err := e.start(); err != nil {
+			respondError(e.c, "Failed to export "+e.name, err)
+			return
+		}
+	}
+	if err := e.writer.Close(); err != nil {
+		log.Printf("[ERROR] %s %s: closing export of %s: %v", e.c.Request.Method, e.c.Request.URL.Path, e.name, err)
+	}
+}
+
+func (e *exporter) start() error {
+	filename := fmt.Sprintf("%s-%s.%s", e.name, time.Now().UTC().Format("20060102-150405"), e.format)
+	e.c.Header("Content-Type", exportContentTypes[e.format])
+	e.c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	e.c.Status(200)
+
+	// A large export may stream for longer than the server's write timeout allows
+	_ = http.NewResponseController(e.c.Writer).SetWriteDeadline(time.Time{})
+
+	var err error
+	switch e.format {
+	case "ndjson":
+		e.writer = &ndjsonWriter{w: e.c.Writer, columns: e.columns}
+	case "xlsx":
+		e.writer, err = newXLSXWriter(e.c.Writer, e.name, e.columns)
+	default:
+		e.writer, err = newCSVWriter(e.c.Writer, e.columns)
+	}
+	return err
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer, columns []string) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	return cw, cw.w.Write(columns)
+}
+
+func (cw *csvWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatCell(v)
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// ndjsonWriter writes one JSON object per row with the keys in column order
+type ndjsonWriter struct {
+	w       io.Writer
+	columns []string
+}
+
+func (nw *ndjsonWriter) WriteRow(values []any) error {
+	line := []byte{'{'}
+	for i, v := range values {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		key, _ := json.Marshal(nw.columns[i])
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		line = append(append(append(line, key...), ':'), value...)
+	}
+	_, err := nw.w.Write(append(line, '}', '\n'))
+	return err
+}
+
+func (nw *ndjsonWriter) Flush() error {
+	return nil
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
+
+// xlsxWriter streams a single sheet workbook. The fixed package parts are
+// written first, the sheet is written row by row as the last zip entry.
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+	row   int
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+func newXLSXWriter(w io.Writer, sheetName string, columns []string) (*xlsxWriter, error) {
+	z := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, sheetName)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		f, err := z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xlsxSheetStart); err != nil {
+		return nil, err
+	}
+
+	xw := &xlsxWriter{zip: z, sheet: sheet}
+	header := make([]any, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	return xw, xw.WriteRow(header)
+}
+
+func (xw *xlsxWriter) WriteRow(values []any) error {
+	xw.row++
+	buf := []byte(`<row r="` + strconv.Itoa(xw.row) + `">`)
+	for i, v := range values {
+		ref := xlsxColumn(i) + strconv.Itoa(xw.row)
+		switch n := v.(type) {
+		case int64:
+			buf = append(buf, `<c r="`+ref+`"><v>`+strconv.FormatInt(n, 10)+`</v></c>`...)
+		case float64:
+			buf = append(buf, `<c r="`+ref+`"><v>`+strconv.FormatFloat(n, 'f', -1, 64)+`</v></c>`...)
+		default:
+			var text strings.Builder
+			xml.EscapeText(&text, []byte(formatCell(v)))
+			buf = append(buf, `<c r="`+ref+`" t="inlineStr"><is><t>`+text.String()+`</t></is></c>`...)
+		}
+	}
+	_, err := xw.sheet.Write(append(buf, "</row>"...))
+	return err
+}
+
+func (xw *xlsxWriter) Flush() error {
+	return xw.zip.Flush()
+}
+
+func (xw *xlsxWriter) Close() error {
+	if _, err := io.WriteString(xw.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return xw.zip.Close()
+}
+
+// xlsxColumn turns a zero based column index into its letters, 0 is A and 26 is AA
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// formatCell renders a cell for the text based formats
+func formatCell(v any) string {
+	switch v := v.(type) {
+	case string:
+		return escapeFormula(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case uuid.UUID:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formulaPrefixes are the leading characters that make spreadsheet applications
+// evaluate a cell as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula prefixes text a spreadsheet would run as a formula with a quote,
+// so names from the API cannot inject formulas into the exported files
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
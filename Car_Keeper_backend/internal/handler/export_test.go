@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFormatCellEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{value: "Civic", want: "Civic"},
+		{value: "", want: ""},
+		{value: "=HYPERLINK(\"http://evil\")", want: "'=HYPERLINK(\"http://evil\")"},
+		{value: "+1", want: "'+1"},
+		{value: "-1", want: "'-1"},
+		{value: "@SUM(A1)", want: "'@SUM(A1)"},
+		{value: "\tcmd", want: "'\tcmd"},
+		{value: "\rcmd", want: "'\rcmd"},
+		{value: "a=b", want: "a=b"},
+		{value: int64(-5), want: "-5"},
+		{value: float64(-1.5), want: "-1.5"},
+	}
+	for _, tt := range tests {
+		if got := formatCell(tt.value); got != tt.want {
+			t.Errorf("formatCell(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCSVWriterEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newCSVWriter(&buf, []string{"name", "price"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow([]any{"=1+1", float64(-3)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "name,price\n'=1+1,-3\n"; buf.String() != want {
+		t.Errorf("csv = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestRejectExportPaging(t *testing.T) {
+	tests := []struct {
+		query  string
+		reject bool
+	}{
+		{query: "", reject: false},
+		{query: "format=csv&brand=Honda&sort=-price", reject: false},
+		{query: "limit=10", reject: true},
+		{query: "offset=0", reject: true},
+		{query: "cursor=abc", reject: true},
+	}
+	for _, tt := range tests {
+		c, w := newHeaderContext("", "")
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/cars/export?"+tt.query, nil)
+		if got := rejectExportPaging(c); got != tt.reject {
+			t.Errorf("rejectExportPaging(%q) = %v, want %v", tt.query, got, tt.reject)
+		}
+		if tt.reject && w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	}
+}
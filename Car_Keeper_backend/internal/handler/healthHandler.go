@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"Car_Keeper/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler serves the Kubernetes probes. They are polled every few
+// seconds, so unlike the API handlers they start no spans of their own; main
+// leaves their paths out of otelgin and the checks run their queries untraced.
+type HealthHandler struct {
+	registry *health.Registry
+}
+
+func NewHealthHandler(registry *health.Registry) *HealthHandler {
+	return &HealthHandler{registry: registry}
+}
+
+// Livez reports that the process is up and serving, dependencies are not checked
+// so a database outage does not get the pod restarted
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(200, gin.H{"status": health.StatusOK})
+}
+
+// Readyz runs the registered checks and answers 503 when one of them fails or
+// the server is shutting down
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	report := h.registry.Run(c.Request.Context())
+	status := 200
+	if report.Status != health.StatusOK {
+		status = 503
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"Car_Keeper/internal/dto"
+	"Car_Keeper/internal/models"
+	"Car_Keeper/pkg/response"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	maxImportBytes = 10 << 20
+	maxImportRows  = 10000
+)
+
+// carImportColumns are the CSV columns of a car import. The engine is given by
+// engine_id or by the three engine_ spec columns.
+var carImportColumns = map[string]bool{
+	"name": true, "year": true, "brand": true, "fuel_type": true, "price": true,
+	"engine_id": true, "engine_displacement": true, "engine_no_of_cylinders": true, "engine_car_range": true,
+}
+
+// importFileError rejects the file as a whole, its message is meant for the client
+type importFileError struct {
+	message string
+}
+
+func (e *importFileError) Error() string {
+	return e.message
+}
+
+var errTooManyRows = &importFileError{message: fmt.Sprintf("an import holds at most %d rows", maxImportRows)}
+
+// bindCarImport parses a CSV or NDJSON import body and validates every row with
+// the CarRequest rules. Rows that fail are reported in the returned errors
+// instead of failing the request; false means a problem response was written.
+func bindCarImport(c *gin.Context, opts dto.ImportOptions) (*dto.CarImport, bool) {
+	if opts.Mode == "" {
+		opts.Mode = dto.ImportModeAtomic
+	}
+	carImport := &dto.CarImport{ImportOptions: opts}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+
+	var err error
+	switch mediaType {
+	case "text/csv":
+		err = parseCSVImport(body, carImport)
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		err = parseNDJSONImport(body, carImport)
+	default:
+		response.Error(c, 415, "Import accepts text/csv or application/x-ndjson")
+		return nil, false
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		response.Error(c, 413, fmt.Sprintf("An import is at most %d bytes", maxImportBytes))
+		return nil, false
+	case err != nil:
+		logClientError(c, "import not parsed", err)
+		response.Error(c, 400, importParseMessage(err))
+		return nil, false
+	case carImport.Total == 0:
+		response.Error(c, 400, "The import file has no rows")
+		return nil, false
+	}
+	return carImport, true
+}
+
+// importParseMessage describes why the file could not be parsed without exposing decoder errors
+func importParseMessage(err error) string {
+	var fileErr *importFileError
+	var csvErr *csv.ParseError
+	switch {
+	case errors.As(err, &fileErr):
+		return "The import file could not be parsed: " + fileErr.message
+	case errors.As(err, &csvErr):
+		return fmt.Sprintf("The import file could not be parsed: line %d is not valid CSV", csvErr.StartLine)
+	case errors.Is(err, bufio.ErrTooLong):
+		return "The import file could not be parsed: a line is too long"
+	default:
+		return "The import file could not be parsed"
+	}
+}
+
+func parseCSVImport(body io.Reader, carImport *dto.CarImport) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if !carImportColumns[header[i]] {
+			return &importFileError{message: fmt.Sprintf("unknown column %q", column)}
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if carImport.Total++; carImport.Total > maxImportRows {
+			return errTooManyRows
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			carImport.Errors = append(carImport.Errors, dto.ImportRowError{
+				Row:     line,
+				Message: fmt.Sprintf("has %d fields, the header has %d", len(record), len(header)),
+			})
+			continue
+		}
+
+		values := make(map[string]string, len(header))
+		for i, column := range header {
+			values[column] = strings.TrimSpace(record[i])
+		}
+		row, rowErrors := csvImportRow(line, values)
+		addImportRow(carImport, row, rowErrors)
+	}
+}
+
+// csvImportRow converts the cells of one CSV row, reporting cells that are not numbers or UUIDs
+func csvImportRow(line int, values map[string]string) (dto.CarImportRow, []dto.ImportRowError) {
+	row := dto.CarImportRow{Row: line}
+	row.Name = values["name"]
+	row.Year = values["year"]
+	row.Brand = values["brand"]
+	row.FuelType = values["fuel_type"]
+
+	var rowErrors []dto.ImportRowError
+	fail := func(field, message string) {
+		rowErrors = append(rowErrors, dto.ImportRowError{Row: line, Field: field, Message: message})
+	}
+
+	if v := values["price"]; v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			fail("price", "must be a number")
+		}
+		row.Price = price
+	}
+	if v := values["engine_id"]; v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			fail("engine_id", "must be a valid UUID")
+		}
+		row.EngineID = id
+	}
+
+	var spec models.EngineRequest
+	specColumns := []struct {
+		column string
+		field  string
+		value  *int64
+	}{
+		{"engine_displacement", "engine.displacement", &spec.Displacement},
+		{"engine_no_of_cylinders", "engine.no_of_cylinders", &spec.NoOfCylinders},
+		{"engine_car_range", "engine.car_range", &spec.CarRange},
+	}
+	for _, col := range specColumns {
+		v := values[col.column]
+		if v == "" {
+			continue
+		}
+		row.Engine = &spec
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			fail(col.field, "must be a whole number")
+		}
+		*col.value = n
+	}
+	return row, rowErrors
+}
+
+func parseNDJSONImport(body io.Reader, carImport *dto.CarImport) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxImportBytes)
+
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if carImport.Total++; carImport.Total > maxImportRows {
+			return errTooManyRows
+		}
+
+		row := dto.CarImportRow{Row: line}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row); err != nil {
+			rowError := dto.ImportRowError{Row: line, Message: "is not a valid car object"}
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				rowError.Field, rowError.Message = typeErr.Field, "must be of type "+typeErr.Type.String()
+			} else if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+				rowError.Field, rowError.Message = strings.Trim(field, `"`), "is not a car field"
+			}
+			carImport.Errors = append(carImport.Errors, rowError)
+			continue
+		}
+		addImportRow(carImport, row, nil)
+	}
+	return scanner.Err()
+}
+
+// addImportRow validates a parsed row and files it as valid or failed. Fields
+// that could not be parsed are reported once, not again by the validation.
+func addImportRow(carImport *dto.CarImport, row dto.CarImportRow, parseErrors []dto.ImportRowError) {
+	rowErrors := parseErrors
+	for _, e := range validateImportRow(&row) {
+		if !slices.ContainsFunc(parseErrors, func(p dto.ImportRowError) bool { return p.Field == e.Field }) {
+			rowErrors = append(rowErrors, e)
+		}
+	}
+	if len(rowErrors) > 0 {
+		carImport.Errors = append(carImport.Errors, rowErrors...)
+		return
+	}
+	carImport.Rows = append(carImport.Rows, row)
+}
+
+// validateImportRow applies the CarRequest binding rules
+func validateImportRow(row *dto.CarImportRow) []dto.ImportRowError {
+	var rowErrors []dto.ImportRowError
+	var validationErrs validator.ValidationErrors
+	if errors.As(binding.Validator.ValidateStruct(&row.CarRequest), &validationErrs) {
+		for _, fe := range validationErrs {
+			rowErrors = append(rowErrors, dto.ImportRowError{Row: row.Row, Field: fieldPath(fe), Message: validationMessage(fe)})
+		}
+	}
+	return rowErrors
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"Car_Keeper/internal/dto"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const importEngineID = "e1f86b1a-0873-4c19-bae2-fc60329d0140"
+
+type importCase struct {
+	name string
+	body string
+	// wantFileErr is the message for a file that is rejected as a whole
+	wantFileErr string
+	wantTotal   int
+	wantValid   int
+	// wantErrors are the failed rows as "row:field"
+	wantErrors []string
+}
+
+func runImportCases(t *testing.T, parse func(io.Reader, *dto.CarImport) error, tests []importCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carImport := &dto.CarImport{}
+			err := parse(strings.NewReader(tt.body), carImport)
+			if tt.wantFileErr != "" {
+				if err == nil {
+					t.Fatal("file accepted, want an error")
+				}
+				if msg := importParseMessage(err); msg != tt.wantFileErr {
+					t.Errorf("message = %q, want %q", msg, tt.wantFileErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if carImport.Total != tt.wantTotal || len(carImport.Rows) != tt.wantValid {
+				t.Errorf("total %d, valid %d, want %d and %d", carImport.Total, len(carImport.Rows), tt.wantTotal, tt.wantValid)
+			}
+			var got []string
+			for _, e := range carImport.Errors {
+				got = append(got, fmt.Sprintf("%d:%s", e.Row, e.Field))
+			}
+			if !reflect.DeepEqual(got, tt.wantErrors) {
+				t.Errorf("errors = %v, want %v", got, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestParseCSVImport(t *testing.T) {
+	header := "name,year,brand,fuel_type,price,engine_id\n"
+	runImportCases(t, parseCSVImport, []importCase{
+		{
+			name:      "valid rows",
+			body:      header + "Civic,2023,Honda,petrol,25000," + importEngineID + "\nModel 3,2024,Tesla,electric,40000," + importEngineID + "\n",
+			wantTotal: 2,
+			wantValid: 2,
+		},
+		{
+			name:      "empty file",
+			body:      "",
+			wantTotal: 0,
+		},
+		{
+			name:      "header is case and space insensitive",
+			body:      " Name ,YEAR,brand,fuel_type,price,engine_id\nCivic,2023,Honda,petrol,25000," + importEngineID + "\n",
+			wantTotal: 1,
+			wantValid: 1,
+		},
+		{
+			name:       "unparsable cells are reported once",
+			body:       header + "Civic,2023,Honda,petrol,cheap,not-a-uuid\n",
+			wantTotal:  1,
+			wantErrors: []string{"2:price", "2:engine_id"},
+		},
+		{
+			name:       "validation rules of CarRequest",
+			body:       header + "Civic,23,Honda,coal,25000," + importEngineID + "\n",
+			wantTotal:  1,
+			wantErrors: []string{"2:year", "2:fuel_type"},
+		},
+		{
+			name:       "wrong number of fields",
+			body:       header + "Civic,2023,Honda\n",
+			wantTotal:  1,
+			wantErrors: []string{"2:"},
+		},
+		{
+			name:       "engine spec instead of engine_id",
+			body:       "name,year,brand,fuel_type,price,engine_displacement,engine_no_of_cylinders,engine_car_range\nCivic,2023,Honda,petrol,25000,2000,4,x\n",
+			wantTotal:  1,
+			wantErrors: []string{"2:engine.car_range"},
+		},
+		{
+			name:        "unknown column",
+			body:        "name,color\nCivic,red\n",
+			wantFileErr: `The import file could not be parsed: unknown column "color"`,
+		},
+		{
+			name:        "malformed CSV",
+			body:        header + "\"Civic,2023\n",
+			wantFileErr: "The import file could not be parsed: line 2 is not valid CSV",
+		},
+		{
+			name:        "too many rows",
+			body:        header + strings.Repeat("Civic,2023,Honda,petrol,25000,"+importEngineID+"\n", maxImportRows+1),
+			wantFileErr: "The import file could not be parsed: " + errTooManyRows.message,
+		},
+	})
+}
+
+func TestParseNDJSONImport(t *testing.T) {
+	valid := `{"name":"Civic","year":"2023","brand":"Honda","fuel_type":"petrol","price":25000,"engine_id":"` + importEngineID + `"}`
+	runImportCases(t, parseNDJSONImport, []importCase{
+		{
+			name:      "valid rows and blank lines",
+			body:      valid + "\n\n" + valid + "\n",
+			wantTotal: 2,
+			wantValid: 2,
+		},
+		{
+			name:      "inline engine",
+			body:      `{"name":"Civic","year":"2023","brand":"Honda","fuel_type":"petrol","price":25000,"engine":{"displacement":2000,"no_of_cylinders":4,"car_range":600}}`,
+			wantTotal: 1,
+			wantValid: 1,
+		},
+		{
+			name:       "wrong type",
+			body:       valid + "\n" + `{"name":"Civic","year":2023}`,
+			wantTotal:  2,
+			wantValid:  1,
+			wantErrors: []string{"2:year"},
+		},
+		{
+			name:       "unknown field",
+			body:       `{"name":"Civic","color":"red"}`,
+			wantTotal:  1,
+			wantErrors: []string{"1:color"},
+		},
+		{
+			name:       "not JSON",
+			body:       `{"name":`,
+			wantTotal:  1,
+			wantErrors: []string{"1:"},
+		},
+		{
+			name:       "engine_id and engine together",
+			body:       `{"name":"Civic","year":"2023","brand":"Honda","fuel_type":"petrol","price":25000,"engine_id":"` + importEngineID + `","engine":{"displacement":2000,"no_of_cylinders":4,"car_range":600}}`,
+			wantTotal:  1,
+			wantErrors: []string{"1:engine_id"},
+		},
+	})
+}
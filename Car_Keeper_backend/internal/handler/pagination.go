@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"Car_Keeper/internal/dto"
+	"Car_Keeper/internal/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newListResponse wraps a page with totals and next/prev links built from the current request URL.
+// Offset requests get offset links, cursor requests get cursor links.
+func newListResponse[T any](c *gin.Context, req models.PageRequest, page *models.Page[T]) dto.ListResponse {
+	items := page.Items
+	if items == nil {
+		items = []T{}
+	}
+
+	resp := dto.ListResponse{
+		Data: items,
+		Meta: dto.ListMeta{
+			Total:      page.Total,
+			Count:      len(page.Items),
+			Limit:      page.Limit,
+			NextCursor: page.NextCursor,
+			PrevCursor: page.PrevCursor,
+		},
+		Links: dto.ListLinks{Self: c.Request.URL.RequestURI()},
+	}
+
+	if req.Cursor != "" {
+		if page.NextCursor != "" {
+			resp.Links.Next = pageLink(c, "cursor", page.NextCursor)
+		}
+		if page.PrevCursor != "" {
+			resp.Links.Prev = pageLink(c, "cursor", page.PrevCursor)
+		}
+		return resp
+	}
+
+	offset := page.Offset
+	resp.Meta.Offset = &offset
+	if int64(offset+len(page.Items)) < page.Total {
+		resp.Links.Next = pageLink(c, "offset", strconv.Itoa(offset+page.Limit))
+	}
+	if offset > 0 {
+		resp.Links.Prev = pageLink(c, "offset", strconv.Itoa(max(offset-page.Limit, 0)))
+	}
+	return resp
+}
+
+func pageLink(c *gin.Context, key, value string) string {
+	u := *c.Request.URL
+	q := u.Query()
+	q.Del("offset")
+	q.Del("cursor")
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"Car_Keeper/pkg/response"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// bindPatch applies the request body to current and decodes the result into target,
+// which is then validated like a PUT body. The body is a JSON Merge Patch (RFC 7396,
+// also accepted as plain application/json) or a JSON Patch (RFC 6902). On failure
+// the problem response has already been written and false is returned.
+func bindPatch(c *gin.Context, current interface{}, target interface{}) bool {
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response.Error(c, 400, "Failed to read the request body")
+		return false
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		respondError(c, "Failed to apply patch", err)
+		return false
+	}
+
+	var patched []byte
+	switch mediaType {
+	case mergePatchContentType, binding.MIMEJSON:
+		if patched, err = jsonpatch.MergePatch(doc, body); err != nil {
+			logClientError(c, "invalid merge patch", err)
+			response.Error(c, 400, "The patch document is not a valid JSON object")
+			return false
+		}
+	case jsonPatchContentType:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			logClientError(c, "invalid JSON patch", err)
+			response.Error(c, 400, "The patch document is not a valid JSON Patch array")
+			return false
+		}
+		if patched, err = patch.Apply(doc); err != nil {
+			logClientError(c, "JSON patch not applicable", err)
+			response.Error(c, patchApplyStatus(err), patchApplyMessage(err))
+			return false
+		}
+	default:
+		response.Error(c, 415, "PATCH accepts "+mergePatchContentType+" or "+jsonPatchContentType)
+		return false
+	}
+
+	// Unknown fields are rejected so that patching id or created_at fails loudly
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			response.WriteProblem(c, response.Problem{
+				Status: 422,
+				Detail: "The request has invalid fields",
+				Errors: []response.FieldError{{Field: strings.Trim(field, `"`), Message: "cannot be changed"}},
+			})
+			return false
+		}
+		respondBindError(c, err)
+		return false
+	}
+
+	if err := binding.Validator.ValidateStruct(target); err != nil {
+		respondBindError(c, err)
+		return false
+	}
+	return true
+}
+
+func patchApplyStatus(err error) int {
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return 409
+	}
+	return 400
+}
+
+// patchApplyMessage describes why a JSON Patch could not be applied without
+// exposing the library's error text
+func patchApplyMessage(err error) string {
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		return "A test operation of the patch failed"
+	case errors.Is(err, jsonpatch.ErrMissing), errors.Is(err, jsonpatch.ErrInvalidIndex):
+		return "A patch operation refers to a path that does not exist"
+	default:
+		return "A patch operation could not be applied"
+	}
+}
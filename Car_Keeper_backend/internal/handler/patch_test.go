@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"Car_Keeper/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestBindPatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engineID := uuid.MustParse("0b7e1a52-4f1c-4b8e-9d2a-6c3f5e7a9b10")
+	current := models.CarRequest{
+		Name:     "Corolla",
+		Year:     "2020",
+		Brand:    "Toyota",
+		FuelType: "petrol",
+		EngineID: engineID,
+		Price:    20000,
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int // 0 when the patch applies
+		want        func(*models.CarRequest) bool
+	}{
+		{
+			name:        "merge patch changes only the given fields",
+			contentType: mergePatchContentType,
+			body:        `{"price": 18500, "fuel_type": "hybrid"}`,
+			want: func(r *models.CarRequest) bool {
+				return r.Price == 18500 && r.FuelType == "hybrid" && r.Name == "Corolla" && r.EngineID == engineID
+			},
+		},
+		{
+			name:        "plain JSON is a merge patch",
+			contentType: "application/json",
+			body:        `{"name": "Corolla Cross"}`,
+			want:        func(r *models.CarRequest) bool { return r.Name == "Corolla Cross" && r.Year == "2020" },
+		},
+		{
+			name:        "merge patch null clears a field and fails validation",
+			contentType: mergePatchContentType,
+			body:        `{"brand": null}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "merge patch with an unknown field",
+			contentType: mergePatchContentType,
+			body:        `{"id": "3f7c1c7e-0000-0000-0000-000000000000"}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "merge patch that is not JSON",
+			contentType: mergePatchContentType,
+			body:        `{"price":`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "JSON patch replace and test",
+			contentType: jsonPatchContentType,
+			body:        `[{"op": "test", "path": "/year", "value": "2020"}, {"op": "replace", "path": "/year", "value": "2021"}]`,
+			want:        func(r *models.CarRequest) bool { return r.Year == "2021" && r.Price == 20000 },
+		},
+		{
+			name:        "JSON patch failing test",
+			contentType: jsonPatchContentType,
+			body:        `[{"op": "test", "path": "/year", "value": "1999"}, {"op": "replace", "path": "/year", "value": "2021"}]`,
+			wantStatus:  http.StatusConflict,
+		},
+		{
+			name:        "JSON patch on a missing path",
+			contentType: jsonPatchContentType,
+			body:        `[{"op": "replace", "path": "/color", "value": "red"}]`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "JSON patch that is not an array",
+			contentType: jsonPatchContentType,
+			body:        `{"op": "replace"}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "JSON patch producing an invalid car",
+			contentType: jsonPatchContentType,
+			body:        `[{"op": "replace", "path": "/year", "value": "20"}]`,
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/plain",
+			body:        `price=1`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPatch, "/api/v1/cars/1", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", tt.contentType)
+
+			doc := current
+			var target models.CarRequest
+			ok := bindPatch(c, &doc, &target)
+
+			if tt.wantStatus != 0 {
+				if ok {
+					t.Fatalf("patch applied, want status %d", tt.wantStatus)
+				}
+				if w.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+				}
+				if strings.Contains(w.Body.String(), "jsonpatch") || strings.Contains(w.Body.String(), "unexpected EOF") {
+					t.Errorf("problem leaks library errors: %s", w.Body.String())
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("patch rejected with %d: %s", w.Code, w.Body.String())
+			}
+			if !tt.want(&target) {
+				t.Errorf("unexpected result %+v", target)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"Car_Keeper/internal/dto"
+	"Car_Keeper/internal/models"
+	"Car_Keeper/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+)
+
+type TrashHandler struct {
+	service service.TrashService
+}
+
+func NewTrashHandler(service service.TrashService) *TrashHandler {
+	return &TrashHandler{service: service}
+}
+
+// List the soft-deleted cars, most recently deleted first
+func (h *TrashHandler) ListDeletedCars(c *gin.Context) {
+	tracer := otel.Tracer("TrashHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "ListDeletedCars-Handler")
+	defer span.End()
+
+	var req models.PageRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	page, err := h.service.ListDeletedCars(ctx, &req)
+	if err != nil {
+		respondError(c, "Failed to list deleted cars", err)
+		return
+	}
+
+	// DeletedAt is hidden in the car JSON, the trash shows it
+	trashed := trashedPage(page, func(car models.Car) dto.TrashedCar {
+		return dto.TrashedCar{Car: car, DeletedAt: car.DeletedAt.Time}
+	})
+	c.JSON(200, newListResponse(c, req, trashed))
+}
+
+// List the soft-deleted engines, most recently deleted first
+func (h *TrashHandler) ListDeletedEngines(c *gin.Context) {
+	tracer := otel.Tracer("TrashHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "ListDeletedEngines-Handler")
+	defer span.End()
+
+	var req models.PageRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	page, err := h.service.ListDeletedEngines(ctx, &req)
+	if err != nil {
+		respondError(c, "Failed to list deleted engines", err)
+		return
+	}
+
+	trashed := trashedPage(page, func(engine models.Engine) dto.TrashedEngine {
+		return dto.TrashedEngine{Engine: engine, DeletedAt: engine.DeletedAt.Time}
+	})
+	c.JSON(200, newListResponse(c, req, trashed))
+}
+
+func (h *TrashHandler) RestoreCar(c *gin.Context) {
+	tracer := otel.Tracer("TrashHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "RestoreCar-Handler")
+	defer span.End()
+
+	if err := h.service.RestoreCar(ctx, c.Param("carid")); err != nil {
+		respondError(c, "Failed to restore car", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Car restored successfully"})
+}
+
+func (h *TrashHandler) RestoreEngine(c *gin.Context) {
+	tracer := otel.Tracer("TrashHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "RestoreEngine-Handler")
+	defer span.End()
+
+	if err := h.service.RestoreEngine(ctx, c.Param("engineid")); err != nil {
+		respondError(c, "Failed to restore engine", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Engine restored successfully"})
+}
+
+// Permanently delete a car from the trash
+func (h *TrashHandler) PurgeCar(c *gin.Context) {
+	tracer := otel.Tracer("TrashHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "PurgeCar-Handler")
+	defer span.End()
+
+	if err := h.service.PurgeCar(ctx, c.Param("carid")); err != nil {
+		respondError(c, "Failed to purge car", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Car purged successfully"})
+}
+
+// Permanently delete an engine from the trash
+func (h *TrashHandler) PurgeEngine(c *gin.Context) {
+	tracer := otel.Tracer("TrashHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "PurgeEngine-Handler")
+	defer span.End()
+
+	if err := h.service.PurgeEngine(ctx, c.Param("engineid")); err != nil {
+		respondError(c, "Failed to purge engine", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Engine purged successfully"})
+}
+
+// trashedPage converts the items of page, keeping the pagination
+func trashedPage[T, U any](page *models.Page[T], convert func(T) U) *models.Page[U] {
+	items := make([]U, len(page.Items))
+	for i, item := range page.Items {
+		items[i] = convert(item)
+	}
+	return &models.Page[U]{
+		Items:      items,
+		Total:      page.Total,
+		Limit:      page.Limit,
+		Offset:     page.Offset,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+}
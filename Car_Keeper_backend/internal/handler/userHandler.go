@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/dto"
+	"Car_Keeper/internal/service"
+	"Car_Keeper/pkg/response"
+	"Car_Keeper/pkg/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+)
+
+type UserHandler struct {
+	service service.UserService
+	tokens  service.TokenService
+}
+
+func NewUserHandler(service service.UserService, tokens service.TokenService) *UserHandler {
+	return &UserHandler{service: service, tokens: tokens}
+}
+
+func (h *UserHandler) Register(c *gin.Context) {
+	tracer := otel.Tracer("UserHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "Register-Handler")
+	defer span.End()
+
+	var req dto.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, err := h.service.Register(ctx, &req)
+	if err != nil {
+		respondError(c, "Failed to register", err)
+		return
+	}
+	c.JSON(201, user)
+}
+
+func (h *UserHandler) Login(c *gin.Context) {
+	tracer := otel.Tracer("UserHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "Login-Handler")
+	defer span.End()
+
+	var req dto.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	resp, err := h.service.Login(ctx, &req)
+	if err != nil {
+		respondError(c, "Login failed", err)
+		return
+	}
+	c.JSON(200, resp)
+}
+
+// Exchange a refresh token for a new access and refresh token pair
+func (h *UserHandler) Refresh(c *gin.Context) {
+	tracer := otel.Tracer("UserHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "Refresh-Handler")
+	defer span.End()
+
+	var req dto.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	tokens, err := h.tokens.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		respondError(c, "Refresh failed", err)
+		return
+	}
+	c.JSON(200, tokens)
+}
+
+// Revoke the current session and access token
+func (h *UserHandler) Logout(c *gin.Context) {
+	tracer := otel.Tracer("UserHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "Logout-Handler")
+	defer span.End()
+
+	claims, ok := c.Get("claims")
+	if !ok {
+		response.Error(c, 400, "Logout requires a bearer token")
+		return
+	}
+	if err := h.tokens.Logout(ctx, claims.(*utils.Claims)); err != nil {
+		respondError(c, "Logout failed", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Logged out successfully"})
+}
+
+// Get the profile of the authenticated user
+func (h *UserHandler) GetMe(c *gin.Context) {
+	tracer := otel.Tracer("UserHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "GetMe-Handler")
+	defer span.End()
+
+	user, err := h.service.GetUserByID(ctx, c.GetUint("userID"))
+	if err != nil {
+		respondError(c, "Failed to get user", err)
+		return
+	}
+	c.JSON(200, user)
+}
+
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	tracer := otel.Tracer("UserHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "UpdateMe-Handler")
+	defer span.End()
+
+	var req dto.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, err := h.service.UpdateUser(ctx, c.GetUint("userID"), &req)
+	if err != nil {
+		respondError(c, "Failed to update user", err)
+		return
+	}
+	c.JSON(200, user)
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	tracer := otel.Tracer("UserHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "ChangePassword-Handler")
+	defer span.End()
+
+	var req dto.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := h.service.ChangePassword(ctx, c.GetUint("userID"), &req); err != nil {
+		respondError(c, "Failed to change password", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Password changed successfully, please log in again"})
+}
+
+// Change another user's role, admin only
+func (h *UserHandler) UpdateRole(c *gin.Context) {
+	tracer := otel.Tracer("UserHandler")
+	ctx, span := tracer.Start(c.Request.Context(), "UpdateRole-Handler")
+	defer span.End()
+
+	userID, err := strconv.ParseUint(c.Param("userid"), 10, 32)
+	if err != nil {
+		respondError(c, "Invalid user ID", apperrors.InvalidID("user", err))
+		return
+	}
+
+	var req dto.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, err := h.service.UpdateRole(ctx, uint(userID), &req)
+	if err != nil {
+		respondError(c, "Failed to update role", err)
+		return
+	}
+	c.JSON(200, user)
+}
@@ -0,0 +1,114 @@
+// Package health runs the dependency checks behind the readiness probe
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// Check reports whether one dependency is usable, it must honour ctx's deadline
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of all checks, Status is ok only when every check passed
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Registry holds the checks deciding readiness
+type Registry struct {
+	timeout      time.Duration
+	mu           sync.RWMutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry returns an empty registry whose checks each get timeout to finish
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, namedCheck{name: name, check: check})
+}
+
+// SetShuttingDown makes every following report fail, so load balancers stop
+// sending traffic while in-flight requests drain
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Run executes all checks concurrently
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := r.checks
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks)+1)}
+	if r.shuttingDown.Load() {
+		report.Status = StatusFail
+		report.Checks["shutdown"] = CheckResult{Status: StatusFail, Error: errShuttingDown.Error()}
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := r.run(ctx, c.check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{Status: StatusOK, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
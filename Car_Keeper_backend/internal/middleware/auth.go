@@ -1,39 +1,140 @@
 package middleware
 
 import (
+	"Car_Keeper/internal/models"
 	"Car_Keeper/pkg/response"
 	"Car_Keeper/pkg/utils"
+	"context"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// APIKeyAuthenticator resolves the value of the X-API-Key header
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error)
+}
+
+// AuthMiddleware accepts either a Bearer JWT or an X-API-Key header and stores
+// the granted scopes under "scopes". Users get the scopes of their role.
+func AuthMiddleware(apiKeys APIKeyAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			response.Error(c, http.StatusUnauthorized, "Authorization header required")
+		// Identify has authenticated the request already, or failed to
+		if _, ok := c.Get("scopes"); ok {
+			c.Next()
+			return
+		}
+		if message := c.GetString("authError"); message != "" {
+			response.Error(c, http.StatusUnauthorized, message)
 			c.Abort()
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			response.Error(c, http.StatusUnauthorized, "Invalid authorization format")
+		if message, ok := authenticate(c, apiKeys); !ok {
+			response.Error(c, http.StatusUnauthorized, message)
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
+
+// Identify authenticates requests that carry credentials without requiring any,
+// so middleware running before a route's AuthMiddleware, like the rate limiter,
+// knows the client. Invalid credentials are ignored here; the failure is kept
+// under "authError" for AuthMiddleware to reject on the routes that need them.
+func Identify(apiKeys APIKeyAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") != "" || c.GetHeader("Authorization") != "" {
+			if message, ok := authenticate(c, apiKeys); !ok {
+				c.Set("authError", message)
+			}
+		}
+		c.Next()
+	}
+}
 
-		token := parts[1]
-		userID, err := utils.ValidateToken(token)
+// authenticate checks the credentials of the request and stores the client and
+// its scopes in the context. On failure it returns the message for the 401.
+func authenticate(c *gin.Context, apiKeys APIKeyAuthenticator) (string, bool) {
+	if rawKey := c.GetHeader("X-API-Key"); rawKey != "" {
+		key, err := apiKeys.Authenticate(c.Request.Context(), rawKey)
 		if err != nil {
-			response.Error(c, http.StatusUnauthorized, "Invalid token")
+			return "Invalid API key", false
+		}
+
+		c.Set("apiKeyID", key.ID)
+		c.Set("scopes", key.Scopes)
+		return "", true
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return "Authorization header required", false
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "Invalid authorization format", false
+	}
+
+	token := parts[1]
+	claims, err := utils.ValidateToken(c.Request.Context(), token)
+	if err != nil {
+		return "Invalid token", false
+	}
+
+	c.Set("claims", claims)
+	c.Set("userID", claims.UserID)
+	c.Set("role", claims.Role)
+	c.Set("scopes", models.RoleScopes[claims.Role])
+	return "", true
+}
+
+// RequireRole only lets through requests whose token carries one of the given roles.
+// API keys have no role. It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		response.Error(c, http.StatusForbidden, "Insufficient permissions")
+		c.Abort()
+	}
+}
+
+// RequireScope only lets through requests granted every one of the given scopes,
+// whether they come from an API key or a user's role. It must run after AuthMiddleware.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := c.GetStringSlice("scopes")
+		for _, required := range scopes {
+			if !slices.Contains(granted, required) {
+				response.Error(c, http.StatusForbidden, "Missing scope "+required)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireUser only lets through requests authenticated with a user's token, API
+// keys act for no user. It must run after AuthMiddleware.
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("userID"); !ok {
+			response.Error(c, http.StatusForbidden, "This endpoint requires a user token")
 			c.Abort()
 			return
 		}
-
-		c.Set("userID", userID)
 		c.Next()
 	}
 }
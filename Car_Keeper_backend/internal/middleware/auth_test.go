@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"Car_Keeper/internal/models"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// countingAPIKeys accepts the key "valid" and counts the lookups
+type countingAPIKeys struct{ calls int }
+
+func (a *countingAPIKeys) Authenticate(_ context.Context, rawKey string) (*models.APIKey, error) {
+	a.calls++
+	if rawKey != "valid" {
+		return nil, errors.New("unknown key")
+	}
+	return &models.APIKey{Scopes: []string{models.ScopeCarsRead}}, nil
+}
+
+func TestIdentifyAndAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		wantStatus int
+		wantCalls  int
+	}{
+		{name: "valid key", apiKey: "valid", wantStatus: http.StatusOK, wantCalls: 1},
+		{name: "invalid key is looked up once", apiKey: "stolen", wantStatus: http.StatusUnauthorized, wantCalls: 1},
+		{name: "no credentials", wantStatus: http.StatusUnauthorized, wantCalls: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKeys := &countingAPIKeys{}
+			router := gin.New()
+			router.GET("/", Identify(apiKeys), AuthMiddleware(apiKeys), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if apiKeys.calls != tt.wantCalls {
+				t.Errorf("%d key lookups, want %d", apiKeys.calls, tt.wantCalls)
+			}
+		})
+	}
+}
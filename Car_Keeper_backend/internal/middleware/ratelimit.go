@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"Car_Keeper/internal/config"
+	"Car_Keeper/pkg/response"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var rateLimitedCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_rate_limited_total",
+		Help: "Total number of requests rejected by the rate limiter",
+	},
+	[]string{"group", "client_type"},
+)
+
+func init() {
+	prometheus.MustRegister(rateLimitedCounter)
+}
+
+// RateLimitDecision is the outcome of taking a token from a bucket
+type RateLimitDecision struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, only set when not allowed
+}
+
+// RateLimitStore keeps one token bucket per key. MemoryRateLimitStore keeps them
+// in process; a shared store lets several replicas enforce a common quota.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit config.RateLimit) (RateLimitDecision, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration
+}
+
+// MemoryRateLimitStore is a RateLimitStore local to this process
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// memorySweepInterval is how often buckets that refilled completely are dropped
+const memorySweepInterval = time.Minute
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*bucket{}, lastSweep: time.Now(), now: time.Now}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit config.RateLimit) (RateLimitDecision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	capacity := float64(limit.Requests)
+	perToken := limit.Period / time.Duration(limit.Requests)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last, b.period = now, limit.Period
+
+	decision := RateLimitDecision{}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = time.Duration((capacity - b.tokens) * float64(perToken))
+
+	if now.Sub(s.lastSweep) > memorySweepInterval {
+		s.sweep(now)
+	}
+	return decision, nil
+}
+
+// sweep drops the buckets idle long enough to be full again, they behave like new ones
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > b.period {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// RateLimit throttles the requests of one route group per client with a token
+// bucket of limit. The client is the API key or user authenticated by Identify
+// or AuthMiddleware, otherwise the client IP as resolved through the trusted proxies.
+func RateLimit(store RateLimitStore, group string, limit config.RateLimit) gin.HandlerFunc {
+	return rateLimit(store, group, limit, rateLimitClient)
+}
+
+// RateLimitIP throttles the requests of one route group per client IP, whatever
+// credentials they carry. It guards work done before or for authentication.
+func RateLimitIP(store RateLimitStore, group string, limit config.RateLimit) gin.HandlerFunc {
+	return rateLimit(store, group, limit, func(c *gin.Context) (string, string) {
+		return "ip", c.ClientIP()
+	})
+}
+
+func rateLimit(store RateLimitStore, group string, limit config.RateLimit, clientOf func(*gin.Context) (string, string)) gin.HandlerFunc {
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Period.Seconds()))
+
+	return func(c *gin.Context) {
+		clientType, client := clientOf(c)
+		decision, err := store.Take(c.Request.Context(), group+":"+clientType+":"+client, limit)
+		if err != nil {
+			// Better to serve unthrottled than not at all
+			log.Printf("[ERROR] rate limit store: %v", err)
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("RateLimit-Policy", policy)
+		header.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+
+		if !decision.Allowed {
+			retryAfter := ceilSeconds(decision.RetryAfter)
+			rateLimitedCounter.WithLabelValues(group, clientType).Inc()
+			header.Set("Retry-After", strconv.Itoa(retryAfter))
+			response.Error(c, http.StatusTooManyRequests, fmt.Sprintf("Too many requests, retry in %d seconds", retryAfter))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitClient identifies the client a request is counted against
+func rateLimitClient(c *gin.Context) (string, string) {
+	if id, ok := c.Get("apiKeyID"); ok {
+		return "api_key", fmt.Sprint(id)
+	}
+	if id, ok := c.Get("userID"); ok {
+		return "user", fmt.Sprint(id)
+	}
+	return "ip", c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
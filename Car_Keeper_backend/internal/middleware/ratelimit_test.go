@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"Car_Keeper/internal/config"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeClock is a controllable now for MemoryRateLimitStore
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time { return f.t }
+
+func newTestStore() (*MemoryRateLimitStore, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryRateLimitStore()
+	store.now = clock.now
+	store.lastSweep = clock.t
+	return store, clock
+}
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	limit := config.RateLimit{Requests: 3, Period: 3 * time.Second}
+
+	type step struct {
+		advance        time.Duration // before taking
+		wantAllowed    bool
+		wantRemaining  int
+		wantRetryAfter time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "burst up to the limit",
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 2},
+				{wantAllowed: true, wantRemaining: 1},
+				{wantAllowed: true, wantRemaining: 0},
+				{wantAllowed: false, wantRemaining: 0, wantRetryAfter: time.Second},
+			},
+		},
+		{
+			name: "refills one token per period over requests",
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 2},
+				{wantAllowed: true, wantRemaining: 1},
+				{wantAllowed: true, wantRemaining: 0},
+				{advance: 500 * time.Millisecond, wantAllowed: false, wantRetryAfter: 500 * time.Millisecond},
+				{advance: 500 * time.Millisecond, wantAllowed: true, wantRemaining: 0},
+			},
+		},
+		{
+			name: "never refills above the limit",
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 2},
+				{advance: time.Hour, wantAllowed: true, wantRemaining: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, clock := newTestStore()
+			for i, s := range tt.steps {
+				clock.t = clock.t.Add(s.advance)
+				d, err := store.Take(context.Background(), "cars:ip:1.2.3.4", limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if d.Allowed != s.wantAllowed || d.Remaining != s.wantRemaining || d.RetryAfter != s.wantRetryAfter {
+					t.Errorf("step %d: got %+v, want allowed %v, remaining %d, retry after %s", i, d, s.wantAllowed, s.wantRemaining, s.wantRetryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreKeysAreIndependent(t *testing.T) {
+	store, _ := newTestStore()
+	limit := config.RateLimit{Requests: 1, Period: time.Minute}
+
+	for _, key := range []string{"cars:ip:1.2.3.4", "cars:ip:5.6.7.8", "auth:ip:1.2.3.4"} {
+		if d, _ := store.Take(context.Background(), key, limit); !d.Allowed {
+			t.Errorf("%s: first request throttled", key)
+		}
+	}
+	if d, _ := store.Take(context.Background(), "cars:ip:1.2.3.4", limit); d.Allowed {
+		t.Error("second request of the same key allowed")
+	}
+}
+
+func TestMemoryRateLimitStoreSweepsFullBuckets(t *testing.T) {
+	store, clock := newTestStore()
+	limit := config.RateLimit{Requests: 1, Period: time.Second}
+
+	store.Take(context.Background(), "idle", limit)
+	clock.t = clock.t.Add(2 * memorySweepInterval)
+	store.Take(context.Background(), "active", limit)
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("idle bucket was not swept")
+	}
+	if _, ok := store.buckets["active"]; !ok {
+		t.Error("active bucket was swept")
+	}
+}
+
+func TestRateLimitClients(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limit := config.RateLimit{Requests: 1, Period: time.Minute}
+
+	tests := []struct {
+		name string
+		byIP bool
+		// second identifies the second request differently from the first
+		second     func(*gin.Context)
+		wantStatus int
+	}{
+		{name: "same anonymous IP", wantStatus: http.StatusTooManyRequests},
+		{name: "another user", second: func(c *gin.Context) { c.Set("userID", uint(2)) }, wantStatus: http.StatusOK},
+		{name: "another API key", second: func(c *gin.Context) { c.Set("apiKeyID", "key-2") }, wantStatus: http.StatusOK},
+		{name: "by IP ignores the user", byIP: true, second: func(c *gin.Context) { c.Set("userID", uint(2)) }, wantStatus: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := newTestStore()
+			limiter := RateLimit(store, "cars", limit)
+			if tt.byIP {
+				limiter = RateLimitIP(store, "cars", limit)
+			}
+
+			var status int
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				router := gin.New()
+				router.GET("/", func(c *gin.Context) {
+					if i == 1 && tt.second != nil {
+						tt.second(c)
+					}
+					c.Next()
+				}, limiter, func(c *gin.Context) { c.Status(http.StatusOK) })
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				status = w.Code
+			}
+			if status != tt.wantStatus {
+				t.Errorf("second request status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// models/api_key.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Scopes granted to API keys and, through their role, to users
+const (
+	ScopeCarsRead     = "cars:read"
+	ScopeCarsWrite    = "cars:write"
+	ScopeEnginesRead  = "engines:read"
+	ScopeEnginesWrite = "engines:write"
+)
+
+// RoleScopes maps a user role to the scopes it implies
+var RoleScopes = map[string][]string{
+	RoleViewer: {ScopeCarsRead, ScopeEnginesRead},
+	RoleEditor: {ScopeCarsRead, ScopeCarsWrite, ScopeEnginesRead, ScopeEnginesWrite},
+	RoleAdmin:  {ScopeCarsRead, ScopeCarsWrite, ScopeEnginesRead, ScopeEnginesWrite},
+}
+
+// APIKey is a long-lived credential for machine clients. Only the sha256 of the key is stored.
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"not null" json:"prefix"` // first characters of the key, to recognise it
+	KeyHash    string     `gorm:"not null;uniqueIndex" json:"-"`
+	Scopes     []string   `gorm:"serializer:json;not null" json:"scopes"`
+	CreatedBy  uint       `gorm:"not null" json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
@@ -19,6 +19,9 @@ type Car struct {
 	EngineID uuid.UUID `gorm:"type:uuid;not null" json:"engine_id"`
 	Engine   Engine    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"engine"`
 
+	// Version is bumped on every write and served as the ETag
+	Version int64 `gorm:"not null;default:1" json:"version"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
@@ -33,10 +36,38 @@ func (c *Car) BeforeCreate(tx *gorm.DB) error {
 }
 
 type CarRequest struct {
-	Name     string    `json:"name" binding:"required"`
-	Year     string    `json:"year" binding:"required,len=4"`
-	Brand    string    `json:"brand" binding:"required"`
-	FuelType string    `json:"fuel_type" binding:"required,oneof=petrol diesel electric hybrid"`
-	EngineID uuid.UUID `json:"engine_id" binding:"required"` // use ID, not full object
-	Price    float64   `json:"price" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Year     string `json:"year" binding:"required,len=4"`
+	Brand    string `json:"brand" binding:"required"`
+	FuelType string `json:"fuel_type" binding:"required,oneof=petrol diesel electric hybrid"`
+	// The engine is either an existing one by ID or a new one created together with the car
+	EngineID uuid.UUID      `json:"engine_id" binding:"required_without=Engine,excluded_with=Engine"`
+	Engine   *EngineRequest `json:"engine,omitempty"`
+	Price    float64        `json:"price" binding:"required"`
+}
+
+// ToRequest returns the editable fields of the car, used as the document PATCH applies to
+func (c *Car) ToRequest() CarRequest {
+	return CarRequest{
+		Name:     c.Name,
+		Year:     c.Year,
+		Brand:    c.Brand,
+		FuelType: c.FuelType,
+		EngineID: c.EngineID,
+		Price:    c.Price,
+	}
+}
+
+// CarQuery filters, sorts and paginates the car listing
+type CarQuery struct {
+	Name     string   `form:"name"` // case-insensitive substring match
+	Brand    string   `form:"brand"`
+	FuelType string   `form:"fuel_type"`
+	EngineID string   `form:"engine_id" binding:"omitempty,uuid"`
+	YearMin  string   `form:"year_min" binding:"omitempty,len=4,numeric"`
+	YearMax  string   `form:"year_max" binding:"omitempty,len=4,numeric"`
+	PriceMin *float64 `form:"price_min" binding:"omitempty,gte=0"`
+	PriceMax *float64 `form:"price_max" binding:"omitempty,gte=0"`
+
+	PageRequest
 }
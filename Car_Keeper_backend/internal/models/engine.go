@@ -14,6 +14,9 @@ type Engine struct {
 	NoOfCylinders int64     `gorm:"not null;column:no_of_cylinders" json:"no_of_cylinders"`
 	CarRange      int64     `gorm:"not null" json:"car_range"`
 
+	// Version is bumped on every write and served as the ETag
+	Version int64 `gorm:"not null;default:1" json:"version"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
@@ -37,3 +40,42 @@ type EngineRequest struct {
 	NoOfCylinders int64 `json:"no_of_cylinders" binding:"required"`
 	CarRange      int64 `json:"car_range" binding:"required"`
 }
+
+// ToRequest returns the editable fields of the engine, used as the document PATCH applies to
+func (e *Engine) ToRequest() EngineRequest {
+	return EngineRequest{
+		Displacement:  e.Displacement,
+		NoOfCylinders: e.NoOfCylinders,
+		CarRange:      e.CarRange,
+	}
+}
+
+// EngineQuery filters, sorts and paginates the engine listing
+type EngineQuery struct {
+	DisplacementMin *int64 `form:"displacement_min" binding:"omitempty,gte=0"`
+	DisplacementMax *int64 `form:"displacement_max" binding:"omitempty,gte=0"`
+	NoOfCylinders   *int64 `form:"no_of_cylinders" binding:"omitempty,gte=0"`
+	CarRangeMin     *int64 `form:"car_range_min" binding:"omitempty,gte=0"`
+	CarRangeMax     *int64 `form:"car_range_max" binding:"omitempty,gte=0"`
+
+	PageRequest
+}
+
+// EngineSummary is an engine together with the number of cars that use it
+type EngineSummary struct {
+	Engine   `gorm:"embedded"`
+	CarCount int64 `gorm:"column:car_count" json:"car_count"`
+}
+
+// Strategies for deleting an engine that is still used by cars
+const (
+	EngineDeleteRestrict = "restrict" // refuse while cars use the engine
+	EngineDeleteReassign = "reassign" // move the cars to another engine first
+	EngineDeleteCascade  = "cascade"  // delete the cars together with the engine
+)
+
+// EngineDeleteOptions are the query parameters of an engine delete
+type EngineDeleteOptions struct {
+	Strategy string `form:"strategy" binding:"omitempty,oneof=restrict reassign cascade"`
+	To       string `form:"to" binding:"required_if=Strategy reassign,omitempty,uuid"` // engine the cars move to
+}
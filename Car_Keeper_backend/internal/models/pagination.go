@@ -0,0 +1,21 @@
+// models/pagination.go
+package models
+
+// PageRequest holds the pagination and sorting parameters shared by list endpoints.
+// When Cursor is set keyset pagination is used and Offset is ignored.
+type PageRequest struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+	Cursor string `form:"cursor"`
+	Sort   string `form:"sort"` // comma separated fields, prefix with "-" for descending
+}
+
+// Page is a single page of results returned by a list query
+type Page[T any] struct {
+	Items      []T
+	Total      int64
+	Limit      int
+	Offset     int
+	NextCursor string
+	PrevCursor string
+}
@@ -0,0 +1,39 @@
+// models/token.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefreshToken is one link of a rotation chain. All tokens issued from the same
+// login share a FamilyID, which is also the session ID carried in access tokens.
+type RefreshToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	UserID     uint       `gorm:"not null;index"`
+	FamilyID   uuid.UUID  `gorm:"type:uuid;not null;index"`
+	TokenHash  string     `gorm:"not null;uniqueIndex"` // sha256 of the token, never the token itself
+	ExpiresAt  time.Time  `gorm:"not null"`
+	ReplacedBy *uuid.UUID `gorm:"type:uuid"`
+	RevokedAt  *time.Time
+
+	CreatedAt time.Time
+}
+
+// BeforeCreate hook to generate UUID
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// RevokedToken is an entry of the access token revocation list, keyed by jti or session ID
+type RevokedToken struct {
+	ID        string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+
+	CreatedAt time.Time
+}
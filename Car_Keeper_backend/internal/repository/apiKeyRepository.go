@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+)
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+	TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	tracer := otel.Tracer("APIKeyRepository")
+	ctx, span := tracer.Start(ctx, "CreateAPIKey-Repository")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *apiKeyRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	tracer := otel.Tracer("APIKeyRepository")
+	ctx, span := tracer.Start(ctx, "ListAPIKeys-Repository")
+	defer span.End()
+
+	var keys []models.APIKey
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	tracer := otel.Tracer("APIKeyRepository")
+	ctx, span := tracer.Start(ctx, "GetAPIKeyByHash-Repository")
+	defer span.End()
+
+	var key models.APIKey
+	if err := r.db.WithContext(ctx).First(&key, "key_hash = ?", hash).Error; err != nil {
+		return nil, translateError(err, "API key")
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	tracer := otel.Tracer("APIKeyRepository")
+	ctx, span := tracer.Start(ctx, "RevokeAPIKey-Repository")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.NotFound("API key")
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	tracer := otel.Tracer("APIKeyRepository")
+	ctx, span := tracer.Start(ctx, "TouchAPIKey-Repository")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
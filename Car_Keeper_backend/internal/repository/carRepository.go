@@ -1,13 +1,16 @@
 package repository
 
 import (
+	"Car_Keeper/internal/apperrors"
 	"Car_Keeper/internal/models"
 	"context"
-	"fmt"
+	"database/sql"
+	"slices"
 
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type carRepository struct {
@@ -20,10 +23,12 @@ func NewCarRepository(db *gorm.DB) CarRepository {
 
 type CarRepository interface {
 	GetCarByID(ctx context.Context, id string) (*models.Car, error)
-	GetCarByBrand(ctx context.Context, brand string) ([]models.Car, error)
-	CreateCar(ctx context.Context, carReq *models.CarRequest) error
-	UpdateCar(ctx context.Context, id string, carReq *models.CarRequest) error
-	DeleteCar(ctx context.Context, id string) error
+	ListCars(ctx context.Context, query *models.CarQuery) (*models.Page[models.Car], error)
+	StreamCars(ctx context.Context, query *models.CarQuery, fn func(*models.Car) error) error
+	CreateCar(ctx context.Context, carReq *models.CarRequest) (*models.Car, error)
+	CreateCars(ctx context.Context, cars []models.Car) error
+	UpdateCar(ctx context.Context, id string, version int64, carReq *models.CarRequest) (*models.Car, error)
+	DeleteCar(ctx context.Context, id string, version int64) error
 }
 
 func (r *carRepository) GetCarByID(ctx context.Context, id string) (*models.Car, error) {
@@ -34,29 +39,105 @@ func (r *carRepository) GetCarByID(ctx context.Context, id string) (*models.Car,
 	// Parse string to real UUID type
 	carID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid UUID format: %w", err)
+		return nil, apperrors.InvalidID("car", err)
 	}
 
 	var car models.Car
-	if err := r.db.Preload("Engine").First(&car, "id = ?", carID).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).Preload("Engine").First(&car, "id = ?", carID).Error; err != nil {
+		return nil, translateError(err, "car")
 	}
 	return &car, nil
 }
 
-func (r *carRepository) GetCarByBrand(ctx context.Context, brand string) ([]models.Car, error) {
+// carSortColumns are the fields accepted by the sort parameter of ListCars
+var carSortColumns = map[string]sortableColumn[models.Car]{
+	"id":         {column: "cars.id", kind: kindUUID, value: func(c *models.Car) any { return c.ID }},
+	"name":       {column: "cars.name", kind: kindString, value: func(c *models.Car) any { return c.Name }},
+	"brand":      {column: "cars.brand", kind: kindString, value: func(c *models.Car) any { return c.Brand }},
+	"year":       {column: "cars.year", kind: kindString, value: func(c *models.Car) any { return c.Year }},
+	"fuel_type":  {column: "cars.fuel_type", kind: kindString, value: func(c *models.Car) any { return c.FuelType }},
+	"price":      {column: "cars.price", kind: kindFloat, value: func(c *models.Car) any { return c.Price }},
+	"created_at": {column: "cars.created_at", kind: kindTime, value: func(c *models.Car) any { return c.CreatedAt }},
+	"updated_at": {column: "cars.updated_at", kind: kindTime, value: func(c *models.Car) any { return c.UpdatedAt }},
+}
+
+func (r *carRepository) ListCars(ctx context.Context, query *models.CarQuery) (*models.Page[models.Car], error) {
 	tracer := otel.Tracer("CarRepository")
-	ctx, span := tracer.Start(ctx, "GetCarByBrand-Repository")
+	ctx, span := tracer.Start(ctx, "ListCars-Repository")
 	defer span.End()
 
-	var cars []models.Car
-	if err := r.db.Preload("Engine").Where("brand = ?", brand).Find(&cars).Error; err != nil {
+	keys, sort, err := parseSort(query.Sort, carSortColumns, "-created_at", "id")
+	if err != nil {
 		return nil, err
 	}
-	return cars, nil
+
+	db := filterCars(r.db.WithContext(ctx).Model(&models.Car{}), query)
+	return paginate(db.Session(&gorm.Session{}), query.PageRequest, keys, sort, func(tx *gorm.DB) *gorm.DB {
+		return tx.Preload("Engine")
+	})
+}
+
+// StreamCars hands every car matching the listing filters to fn in sort order,
+// joined with its engine. Rows are read from the database cursor one at a time.
+func (r *carRepository) StreamCars(ctx context.Context, query *models.CarQuery, fn func(*models.Car) error) error {
+	tracer := otel.Tracer("CarRepository")
+	ctx, span := tracer.Start(ctx, "StreamCars-Repository")
+	defer span.End()
+
+	keys, _, err := parseSort(query.Sort, carSortColumns, "-created_at", "id")
+	if err != nil {
+		return err
+	}
+
+	db := filterCars(r.db.WithContext(ctx).Model(&models.Car{}), query).
+		Select("cars.id, cars.name, cars.year, cars.brand, cars.fuel_type, cars.price, cars.engine_id, cars.version, cars.created_at, cars.updated_at, " +
+			"engines.engine_id, engines.displacement, engines.no_of_cylinders, engines.car_range, engines.version, engines.created_at, engines.updated_at").
+		Joins("JOIN engines ON engines.engine_id = cars.engine_id").
+		Order(orderClause(keys, false))
+
+	return stream(db, func(rows *sql.Rows) error {
+		var car models.Car
+		err := rows.Scan(&car.ID, &car.Name, &car.Year, &car.Brand, &car.FuelType, &car.Price, &car.EngineID, &car.Version, &car.CreatedAt, &car.UpdatedAt,
+			&car.Engine.EngineID, &car.Engine.Displacement, &car.Engine.NoOfCylinders, &car.Engine.CarRange, &car.Engine.Version, &car.Engine.CreatedAt, &car.Engine.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		return fn(&car)
+	})
+}
+
+// filterCars applies the CarQuery filters shared by listing and export
+func filterCars(db *gorm.DB, query *models.CarQuery) *gorm.DB {
+	if query.Name != "" {
+		db = db.Where("cars.name ILIKE ?", "%"+escapeLike(query.Name)+"%")
+	}
+	if query.Brand != "" {
+		db = db.Where("cars.brand = ?", query.Brand)
+	}
+	if query.FuelType != "" {
+		db = db.Where("cars.fuel_type = ?", query.FuelType)
+	}
+	if query.EngineID != "" {
+		db = db.Where("cars.engine_id = ?", query.EngineID)
+	}
+	if query.YearMin != "" {
+		db = db.Where("cars.year >= ?", query.YearMin)
+	}
+	if query.YearMax != "" {
+		db = db.Where("cars.year <= ?", query.YearMax)
+	}
+	if query.PriceMin != nil {
+		db = db.Where("cars.price >= ?", *query.PriceMin)
+	}
+	if query.PriceMax != nil {
+		db = db.Where("cars.price <= ?", *query.PriceMax)
+	}
+	return db
 }
 
-func (r *carRepository) CreateCar(ctx context.Context, carReq *models.CarRequest) error {
+// CreateCar inserts the car and, when the request holds an inline engine, that
+// engine in the same transaction. The car is returned with its engine loaded.
+func (r *carRepository) CreateCar(ctx context.Context, carReq *models.CarRequest) (*models.Car, error) {
 	tracer := otel.Tracer("CarRepository")
 	ctx, span := tracer.Start(ctx, "CreateCar-Repository")
 	defer span.End()
@@ -70,36 +151,111 @@ func (r *carRepository) CreateCar(ctx context.Context, carReq *models.CarRequest
 		Price:    carReq.Price,
 		EngineID: carReq.EngineID, // ✅ Set foreign key,
 	}
-	// Create the car record in the database
-	return r.db.Create(&car).Error
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if carReq.Engine == nil {
+			if err := lockEngines(tx, carReq.EngineID); err != nil {
+				return err
+			}
+		} else {
+			engine := models.Engine{
+				Displacement:  carReq.Engine.Displacement,
+				NoOfCylinders: carReq.Engine.NoOfCylinders,
+				CarRange:      carReq.Engine.CarRange,
+			}
+			if err := tx.Create(&engine).Error; err != nil {
+				return translateError(err, "engine")
+			}
+			car.EngineID = engine.EngineID
+		}
+
+		// Create the car record in the database
+		if err := tx.Omit("Engine").Create(&car).Error; err != nil {
+			return translateError(err, "car")
+		}
+		return tx.Preload("Engine").First(&car, "id = ?", car.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &car, nil
 }
 
-func (r *carRepository) UpdateCar(ctx context.Context, carID string, carReq *models.CarRequest) error {
+// carBatchSize is the number of rows per INSERT when creating cars in bulk
+const carBatchSize = 500
+
+// CreateCars inserts all cars in one transaction, batchwise
+func (r *carRepository) CreateCars(ctx context.Context, cars []models.Car) error {
+	tracer := otel.Tracer("CarRepository")
+	ctx, span := tracer.Start(ctx, "CreateCars-Repository")
+	defer span.End()
+
+	engineIDs := make([]uuid.UUID, 0, len(cars))
+	for _, car := range cars {
+		if !slices.Contains(engineIDs, car.EngineID) {
+			engineIDs = append(engineIDs, car.EngineID)
+		}
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockEngines(tx, engineIDs...); err != nil {
+			return err
+		}
+		return tx.Omit("Engine").CreateInBatches(cars, carBatchSize).Error
+	})
+	return translateError(err, "car")
+}
+
+// UpdateCar writes the car only while it is still at version, 0 skips the check.
+// The updated car is returned with its engine loaded.
+func (r *carRepository) UpdateCar(ctx context.Context, carID string, version int64, carReq *models.CarRequest) (*models.Car, error) {
 	tracer := otel.Tracer("CarRepository")
 	ctx, span := tracer.Start(ctx, "UpdateCar-Repository")
 	defer span.End()
 
-	// Map CarRequest to Car model
 	// Parse string to real UUID type
 	id, err := uuid.Parse(carID)
 	if err != nil {
-		return fmt.Errorf("invalid UUID format: %w", err)
+		return nil, apperrors.InvalidID("car", err)
 	}
 
-	car := models.Car{
-		ID:       id,
-		Name:     carReq.Name,
-		Year:     carReq.Year,
-		Brand:    carReq.Brand,
-		FuelType: carReq.FuelType,
-		Price:    carReq.Price,
-		EngineID: carReq.EngineID, // ✅ Set foreign key,
+	var car models.Car
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockEngines(tx, carReq.EngineID); err != nil {
+			return err
+		}
+
+		// Only the editable columns are written, so id and created_at stay untouched
+		// and a missing car is reported instead of being inserted
+		update := tx.Model(&models.Car{ID: id})
+		if version != 0 {
+			update = update.Where("version = ?", version)
+		}
+		result := update.Updates(map[string]interface{}{
+			"name":      carReq.Name,
+			"year":      carReq.Year,
+			"brand":     carReq.Brand,
+			"fuel_type": carReq.FuelType,
+			"price":     carReq.Price,
+			"engine_id": carReq.EngineID,
+			"version":   gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return translateError(result.Error, "car")
+		}
+		if result.RowsAffected == 0 {
+			return missingOrStale(tx, &models.Car{}, "id", id, "car")
+		}
+		return tx.Preload("Engine").First(&car, "id = ?", id).Error
+	})
+	if err != nil {
+		return nil, err
 	}
-	// Create the car record in the database
-	return r.db.Save(&car).Error
+	return &car, nil
 }
 
-func (r *carRepository) DeleteCar(ctx context.Context, id string) error {
+// DeleteCar deletes the car only while it is still at version, 0 skips the check
+func (r *carRepository) DeleteCar(ctx context.Context, id string, version int64) error {
 	tracer := otel.Tracer("CarRepository")
 	ctx, span := tracer.Start(ctx, "DeleteCar-Repository")
 	defer span.End()
@@ -107,7 +263,36 @@ func (r *carRepository) DeleteCar(ctx context.Context, id string) error {
 	// Parse string to real UUID type
 	carID, err := uuid.Parse(id)
 	if err != nil {
-		return fmt.Errorf("invalid UUID format: %w", err)
+		return apperrors.InvalidID("car", err)
+	}
+
+	tx := r.db.WithContext(ctx).Where("id = ?", carID)
+	if version != 0 {
+		tx = tx.Where("version = ?", version)
+	}
+	result := tx.Delete(&models.Car{})
+	if result.Error != nil {
+		return translateError(result.Error, "car")
+	}
+	if result.RowsAffected == 0 {
+		return missingOrStale(r.db.WithContext(ctx), &models.Car{}, "id", carID, "car")
+	}
+	return nil
+}
+
+// lockEngines checks that the engines exist and are not deleted and share-locks
+// them until the transaction ends. DeleteEngine locks the engine for update, so a
+// car is never written with an engine that is being moved to the trash.
+func lockEngines(tx *gorm.DB, engineIDs ...uuid.UUID) error {
+	var found []uuid.UUID
+	if err := tx.Model(&models.Engine{}).
+		Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("engine_id IN ?", engineIDs).
+		Pluck("engine_id", &found).Error; err != nil {
+		return translateError(err, "engine")
+	}
+	if len(found) < len(engineIDs) {
+		return apperrors.FieldError(apperrors.ErrValidation, "engine_id", "engine does not exist")
 	}
-	return r.db.Delete(&models.Car{}, carID).Error
+	return nil
 }
@@ -1,11 +1,17 @@
 package repository
 
 import (
+	"Car_Keeper/internal/apperrors"
 	"Car_Keeper/internal/models"
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type engineRepository struct {
@@ -14,9 +20,13 @@ type engineRepository struct {
 
 type EngineRepository interface {
 	GetEngineByID(ctx context.Context, id string) (*models.Engine, error)
+	GetEnginesByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Engine, error)
+	FindEngineBySpec(ctx context.Context, spec *models.EngineRequest) (*models.Engine, error)
+	ListEngines(ctx context.Context, query *models.EngineQuery) (*models.Page[models.EngineSummary], error)
+	StreamEngines(ctx context.Context, query *models.EngineQuery, fn func(*models.EngineSummary) error) error
 	CreateEngine(ctx context.Context, engine *models.Engine) error
 	UpdateEngine(ctx context.Context, engine *models.Engine) error
-	DeleteEngine(ctx context.Context, engineID string) error
+	DeleteEngine(ctx context.Context, engineID string, version int64, opts *models.EngineDeleteOptions) ([]uuid.UUID, error)
 }
 
 func NewEngineRepository(db *gorm.DB) EngineRepository {
@@ -28,33 +38,226 @@ func (r *engineRepository) GetEngineByID(ctx context.Context, id string) (*model
 	ctx, span := tracer.Start(ctx, "GetEngineByID-Repository")
 	defer span.End()
 
+	engineID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperrors.InvalidID("engine", err)
+	}
+
 	var engine models.Engine
-	if err := r.db.First(&engine, "engine_id = ?", id).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).First(&engine, "engine_id = ?", engineID).Error; err != nil {
+		return nil, translateError(err, "engine")
+	}
+	return &engine, nil
+}
+
+func (r *engineRepository) GetEnginesByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Engine, error) {
+	tracer := otel.Tracer("EngineRepository")
+	ctx, span := tracer.Start(ctx, "GetEnginesByIDs-Repository")
+	defer span.End()
+
+	var engines []models.Engine
+	if err := r.db.WithContext(ctx).Where("engine_id IN ?", ids).Find(&engines).Error; err != nil {
+		return nil, translateError(err, "engine")
+	}
+	return engines, nil
+}
+
+// FindEngineBySpec returns the oldest engine with exactly the given displacement,
+// cylinders and range
+func (r *engineRepository) FindEngineBySpec(ctx context.Context, spec *models.EngineRequest) (*models.Engine, error) {
+	tracer := otel.Tracer("EngineRepository")
+	ctx, span := tracer.Start(ctx, "FindEngineBySpec-Repository")
+	defer span.End()
+
+	var engine models.Engine
+	err := r.db.WithContext(ctx).Where("displacement = ? AND no_of_cylinders = ? AND car_range = ?", spec.Displacement, spec.NoOfCylinders, spec.CarRange).
+		Order("created_at, engine_id").
+		First(&engine).Error
+	if err != nil {
+		return nil, translateError(err, "engine")
 	}
 	return &engine, nil
 }
 
+// engineSortColumns are the fields accepted by the sort parameter of ListEngines
+var engineSortColumns = map[string]sortableColumn[models.EngineSummary]{
+	"engine_id":       {column: "engines.engine_id", kind: kindUUID, value: func(e *models.EngineSummary) any { return e.EngineID }},
+	"displacement":    {column: "engines.displacement", kind: kindInt, value: func(e *models.EngineSummary) any { return e.Displacement }},
+	"no_of_cylinders": {column: "engines.no_of_cylinders", kind: kindInt, value: func(e *models.EngineSummary) any { return e.NoOfCylinders }},
+	"car_range":       {column: "engines.car_range", kind: kindInt, value: func(e *models.EngineSummary) any { return e.CarRange }},
+	"created_at":      {column: "engines.created_at", kind: kindTime, value: func(e *models.EngineSummary) any { return e.CreatedAt }},
+	"updated_at":      {column: "engines.updated_at", kind: kindTime, value: func(e *models.EngineSummary) any { return e.UpdatedAt }},
+}
+
+func (r *engineRepository) ListEngines(ctx context.Context, query *models.EngineQuery) (*models.Page[models.EngineSummary], error) {
+	tracer := otel.Tracer("EngineRepository")
+	ctx, span := tracer.Start(ctx, "ListEngines-Repository")
+	defer span.End()
+
+	keys, sort, err := parseSort(query.Sort, engineSortColumns, "-created_at", "engine_id")
+	if err != nil {
+		return nil, err
+	}
+
+	db := filterEngines(r.db.WithContext(ctx).Model(&models.Engine{}), query)
+
+	return paginate(db.Session(&gorm.Session{}), query.PageRequest, keys, sort, r.withCarCounts)
+}
+
+// StreamEngines hands every engine matching the listing filters to fn in sort
+// order, with its car count. Rows are read from the database cursor one at a time.
+func (r *engineRepository) StreamEngines(ctx context.Context, query *models.EngineQuery, fn func(*models.EngineSummary) error) error {
+	tracer := otel.Tracer("EngineRepository")
+	ctx, span := tracer.Start(ctx, "StreamEngines-Repository")
+	defer span.End()
+
+	keys, _, err := parseSort(query.Sort, engineSortColumns, "-created_at", "engine_id")
+	if err != nil {
+		return err
+	}
+
+	db := r.withCarCounts(filterEngines(r.db.WithContext(ctx).Model(&models.Engine{}), query)).Order(orderClause(keys, false))
+	return stream(db, func(rows *sql.Rows) error {
+		var engine models.EngineSummary
+		if err := r.db.ScanRows(rows, &engine); err != nil {
+			return err
+		}
+		return fn(&engine)
+	})
+}
+
+// filterEngines applies the EngineQuery filters shared by listing and export
+func filterEngines(db *gorm.DB, query *models.EngineQuery) *gorm.DB {
+	if query.DisplacementMin != nil {
+		db = db.Where("engines.displacement >= ?", *query.DisplacementMin)
+	}
+	if query.DisplacementMax != nil {
+		db = db.Where("engines.displacement <= ?", *query.DisplacementMax)
+	}
+	if query.NoOfCylinders != nil {
+		db = db.Where("engines.no_of_cylinders = ?", *query.NoOfCylinders)
+	}
+	if query.CarRangeMin != nil {
+		db = db.Where("engines.car_range >= ?", *query.CarRangeMin)
+	}
+	if query.CarRangeMax != nil {
+		db = db.Where("engines.car_range <= ?", *query.CarRangeMax)
+	}
+	return db
+}
+
+// withCarCounts selects the engines with the number of cars referencing each
+// one through the cars.engine_id foreign key
+func (r *engineRepository) withCarCounts(db *gorm.DB) *gorm.DB {
+	carCounts := r.db.Model(&models.Car{}).Select("engine_id, COUNT(*) AS car_count").Group("engine_id")
+	return db.Select("engines.*, COALESCE(car_counts.car_count, 0) AS car_count").
+		Joins("LEFT JOIN (?) AS car_counts ON car_counts.engine_id = engines.engine_id", carCounts)
+}
+
 func (r *engineRepository) CreateEngine(ctx context.Context, engine *models.Engine) error {
 	tracer := otel.Tracer("EngineRepository")
 	ctx, span := tracer.Start(ctx, "CreateEngine-Repository")
 	defer span.End()
 
-	return r.db.Create(engine).Error
+	return translateError(r.db.WithContext(ctx).Create(engine).Error, "engine")
 }
 
+// UpdateEngine writes the engine only while it is still at the version it was read with
 func (r *engineRepository) UpdateEngine(ctx context.Context, engine *models.Engine) error {
 	tracer := otel.Tracer("EngineRepository")
 	ctx, span := tracer.Start(ctx, "UpdateEngine-Repository")
 	defer span.End()
 
-	return r.db.Save(engine).Error
+	result := r.db.WithContext(ctx).Model(engine).Where("version = ?", engine.Version).Updates(map[string]interface{}{
+		"displacement":    engine.Displacement,
+		"no_of_cylinders": engine.NoOfCylinders,
+		"car_range":       engine.CarRange,
+		"version":         gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return translateError(result.Error, "engine")
+	}
+	if result.RowsAffected == 0 {
+		return missingOrStale(r.db.WithContext(ctx), &models.Engine{}, "engine_id", engine.EngineID, "engine")
+	}
+	engine.Version++
+	return nil
 }
 
-func (r *engineRepository) DeleteEngine(ctx context.Context, engineID string) error {
+// DeleteEngine deletes the engine only while it is still at version, 0 skips the
+// check. Cars using the engine are handled by opts.Strategy: restrict refuses the
+// delete, reassign moves them to opts.To and cascade deletes them as well. The IDs
+// of the moved or deleted cars are returned.
+func (r *engineRepository) DeleteEngine(ctx context.Context, engineID string, version int64, opts *models.EngineDeleteOptions) ([]uuid.UUID, error) {
 	tracer := otel.Tracer("EngineRepository")
 	ctx, span := tracer.Start(ctx, "DeleteEngine-Repository")
 	defer span.End()
 
-	return r.db.Delete(&models.Engine{}, "engine_id = ?", engineID).Error
+	id, err := uuid.Parse(engineID)
+	if err != nil {
+		return nil, apperrors.InvalidID("engine", err)
+	}
+
+	var carIDs []uuid.UUID
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the engine row holds back cars being created with it until we are done
+		var engine models.Engine
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&engine, "engine_id = ?", id).Error; err != nil {
+			return translateError(err, "engine")
+		}
+		if version != 0 && engine.Version != version {
+			return apperrors.VersionMismatch("engine")
+		}
+
+		if err := tx.Model(&models.Car{}).Where("engine_id = ?", id).Order("id").Pluck("id", &carIDs).Error; err != nil {
+			return err
+		}
+
+		if len(carIDs) > 0 {
+			switch opts.Strategy {
+			case models.EngineDeleteReassign:
+				if err := reassignCars(tx, id, opts.To); err != nil {
+					return err
+				}
+			case models.EngineDeleteCascade:
+				if err := tx.Where("engine_id = ?", id).Delete(&models.Car{}).Error; err != nil {
+					return translateError(err, "car")
+				}
+			default:
+				return apperrors.New(apperrors.ErrConflict,
+					fmt.Sprintf("engine is still used by %d cars, delete it with strategy=reassign or strategy=cascade", len(carIDs)))
+			}
+		}
+
+		return translateError(tx.Delete(&engine).Error, "engine")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return carIDs, nil
+}
+
+// reassignCars moves the cars of engine from to the engine with ID to
+func reassignCars(tx *gorm.DB, from uuid.UUID, to string) error {
+	toID, err := uuid.Parse(to)
+	if err != nil {
+		return apperrors.FieldError(apperrors.ErrValidation, "to", "must be a valid engine ID")
+	}
+	if toID == from {
+		return apperrors.FieldError(apperrors.ErrValidation, "to", "cannot reassign the cars to the engine being deleted")
+	}
+
+	var target models.Engine
+	if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&target, "engine_id = ?", toID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.FieldError(apperrors.ErrValidation, "to", "engine not found")
+		}
+		return translateError(err, "engine")
+	}
+
+	err = tx.Model(&models.Car{}).Where("engine_id = ?", from).Updates(map[string]interface{}{
+		"engine_id": toID,
+		"version":   gorm.Expr("version + 1"),
+	}).Error
+	return translateError(err, "car")
 }
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"errors"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation        = "23505"
+	pgForeignKeyViolation    = "23503"
+	pgNotNullViolation       = "23502"
+	pgCheckViolation         = "23514"
+	pgStringDataRightTrunc   = "22001"
+	pgInvalidTextRepresent   = "22P02"
+	pgNumericValueOutOfRange = "22003"
+)
+
+// keyColumnPattern extracts the column from details like `Key (engine_id)=(...) is not present`
+var keyColumnPattern = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// translateError turns GORM and Postgres errors into apperrors kinds for resource
+func translateError(err error, resource string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperrors.NotFound(resource)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	appErr := &apperrors.Error{Err: err}
+	if m := keyColumnPattern.FindStringSubmatch(pgErr.Detail); m != nil {
+		appErr.Field = m[1]
+	} else {
+		appErr.Field = pgErr.ColumnName
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		appErr.Kind = apperrors.ErrConflict
+		appErr.Message = resource + " already exists"
+	case pgForeignKeyViolation:
+		appErr.Kind = apperrors.ErrForeignKeyViolation
+		appErr.Message = "referenced resource does not exist or is still in use"
+	case pgNotNullViolation, pgCheckViolation, pgStringDataRightTrunc, pgInvalidTextRepresent, pgNumericValueOutOfRange:
+		appErr.Kind = apperrors.ErrValidation
+		appErr.Message = "invalid value for " + resource
+	default:
+		return err
+	}
+	return appErr
+}
+
+// missingOrStale explains why a versioned write matched no rows: either the row
+// with id no longer exists or its version changed since it was read
+func missingOrStale(db *gorm.DB, model interface{}, column string, id uuid.UUID, resource string) error {
+	var count int64
+	if err := db.Model(model).Where(column+" = ?", id).Count(&count).Error; err != nil {
+		return translateError(err, resource)
+	}
+	if count == 0 {
+		return apperrors.NotFound(resource)
+	}
+	return apperrors.VersionMismatch(resource)
+}
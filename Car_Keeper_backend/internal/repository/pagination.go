@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/models"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const defaultPageLimit = 20
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindInt
+	kindFloat
+	kindTime
+	kindUUID
+)
+
+// sortableColumn maps a public sort field to its SQL column and
+// knows how to read the value back from a row for the cursor.
+type sortableColumn[T any] struct {
+	column string
+	kind   columnKind
+	value  func(*T) any
+}
+
+type sortKey[T any] struct {
+	sortableColumn[T]
+	desc bool
+}
+
+// parseSort turns "-price,year" into sort keys. The tiebreaker field is always
+// appended so that keyset pagination has a total order.
+func parseSort[T any](raw string, allowed map[string]sortableColumn[T], defaultSort, tiebreaker string) ([]sortKey[T], string, error) {
+	if strings.TrimSpace(raw) == "" {
+		raw = defaultSort
+	}
+
+	var keys []sortKey[T]
+	var normalized []string
+	seen := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		desc := strings.HasPrefix(part, "-")
+		field := strings.TrimPrefix(strings.TrimPrefix(part, "-"), "+")
+
+		col, ok := allowed[field]
+		if !ok {
+			return nil, "", apperrors.New(apperrors.ErrInvalidQuery, fmt.Sprintf("unknown sort field %q", field))
+		}
+		if seen[field] {
+			return nil, "", apperrors.New(apperrors.ErrInvalidQuery, fmt.Sprintf("duplicate sort field %q", field))
+		}
+		seen[field] = true
+		keys = append(keys, sortKey[T]{sortableColumn: col, desc: desc})
+		if desc {
+			normalized = append(normalized, "-"+field)
+		} else {
+			normalized = append(normalized, field)
+		}
+	}
+
+	if !seen[tiebreaker] {
+		keys = append(keys, sortKey[T]{sortableColumn: allowed[tiebreaker]})
+		normalized = append(normalized, tiebreaker)
+	}
+	return keys, strings.Join(normalized, ","), nil
+}
+
+type cursorPayload struct {
+	Sort     string   `json:"s"`
+	Values   []string `json:"v"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+func encodeCursor[T any](sort string, keys []sortKey[T], row *T, backward bool) string {
+	payload := cursorPayload{Sort: sort, Backward: backward}
+	for _, k := range keys {
+		payload.Values = append(payload.Values, formatCursorValue(k.value(row)))
+	}
+	raw, _ := json.Marshal(payload)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor[T any](cursor, sort string, keys []sortKey[T]) ([]any, bool, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, false, apperrors.New(apperrors.ErrInvalidQuery, "malformed cursor")
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, false, apperrors.New(apperrors.ErrInvalidQuery, "malformed cursor")
+	}
+	if payload.Sort != sort || len(payload.Values) != len(keys) {
+		return nil, false, apperrors.New(apperrors.ErrInvalidQuery, "cursor does not match the requested sort")
+	}
+
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		v, err := parseCursorValue(k.kind, payload.Values[i])
+		if err != nil {
+			return nil, false, apperrors.New(apperrors.ErrInvalidQuery, "malformed cursor")
+		}
+		values[i] = v
+	}
+	return values, payload.Backward, nil
+}
+
+func formatCursorValue(v any) string {
+	switch val := v.(type) {
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func parseCursorValue(kind columnKind, s string) (any, error) {
+	switch kind {
+	case kindInt:
+		return strconv.ParseInt(s, 10, 64)
+	case kindFloat:
+		return strconv.ParseFloat(s, 64)
+	case kindTime:
+		return time.Parse(time.RFC3339Nano, s)
+	case kindUUID:
+		return uuid.Parse(s)
+	default:
+		return s, nil
+	}
+}
+
+// keysetCondition builds (a > ?) OR (a = ? AND b > ?) ... honoring each key's direction
+func keysetCondition[T any](keys []sortKey[T], values []any, backward bool) (string, []any) {
+	var clauses []string
+	var args []any
+	for i, k := range keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j].column+" = ?")
+			args = append(args, values[j])
+		}
+		op := ">"
+		if k.desc != backward {
+			op = "<"
+		}
+		parts = append(parts, k.column+" "+op+" ?")
+		args = append(args, values[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	return strings.Join(clauses, " OR "), args
+}
+
+func orderClause[T any](keys []sortKey[T], backward bool) string {
+	var parts []string
+	for _, k := range keys {
+		dir := "ASC"
+		if k.desc != backward {
+			dir = "DESC"
+		}
+		parts = append(parts, k.column+" "+dir)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// paginate runs a filtered list query with either offset or keyset pagination.
+// base must already carry the model and filters; fetch adds selects and preloads.
+func paginate[T any](base *gorm.DB, req models.PageRequest, keys []sortKey[T], sort string, fetch func(*gorm.DB) *gorm.DB) (*models.Page[T], error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+
+	page := &models.Page[T]{Limit: limit}
+	if err := base.Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	query := fetch(base)
+	backward := false
+	var cond string
+	var args []any
+	if req.Cursor != "" {
+		values, back, err := decodeCursor(req.Cursor, sort, keys)
+		if err != nil {
+			return nil, err
+		}
+		backward = back
+		cond, args = keysetCondition(keys, values, backward)
+		query = query.Where(cond, args...)
+	} else {
+		page.Offset = req.Offset
+		query = query.Offset(req.Offset)
+	}
+
+	var items []T
+	if err := query.Order(orderClause(keys, backward)).Limit(limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	hasNext, hasPrev := hasMore, req.Offset > 0
+	if req.Cursor != "" {
+		// Rows on the other side of the cursor, the row it was taken from included
+		behind, err := anyRow(base.Where("NOT ("+cond+")", args...))
+		if err != nil {
+			return nil, err
+		}
+		hasPrev = behind
+		if backward {
+			hasNext, hasPrev = behind, hasMore
+		}
+	}
+	if len(items) > 0 {
+		if hasNext {
+			page.NextCursor = encodeCursor(sort, keys, &items[len(items)-1], false)
+		}
+		if hasPrev {
+			page.PrevCursor = encodeCursor(sort, keys, &items[0], true)
+		}
+	}
+
+	page.Items = items
+	return page, nil
+}
+
+// anyRow reports whether query matches at least one row
+func anyRow(query *gorm.DB) (bool, error) {
+	var one int
+	result := query.Select("1").Limit(1).Scan(&one)
+	return result.RowsAffected > 0, result.Error
+}
+
+// escapeLike escapes the LIKE wildcards in user supplied search text
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// stream runs query and hands the rows to scan as they arrive from the database
+// cursor, so exports never hold the whole result in memory
+func stream(query *gorm.DB, scan func(*sql.Rows) error) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type testRow struct {
+	ID        uuid.UUID
+	Name      string
+	Price     float64
+	CreatedAt time.Time
+}
+
+var testSortColumns = map[string]sortableColumn[testRow]{
+	"id":         {column: "id", kind: kindUUID, value: func(r *testRow) any { return r.ID }},
+	"name":       {column: "name", kind: kindString, value: func(r *testRow) any { return r.Name }},
+	"price":      {column: "price", kind: kindFloat, value: func(r *testRow) any { return r.Price }},
+	"created_at": {column: "created_at", kind: kindTime, value: func(r *testRow) any { return r.CreatedAt }},
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		normalized string
+		columns    []string
+		desc       []bool
+		wantErr    bool
+	}{
+		{name: "default", raw: "", normalized: "-created_at,id", columns: []string{"created_at", "id"}, desc: []bool{true, false}},
+		{name: "mixed directions", raw: "-price, +name", normalized: "-price,name,id", columns: []string{"price", "name", "id"}, desc: []bool{true, false, false}},
+		{name: "tiebreaker given", raw: "-id", normalized: "-id", columns: []string{"id"}, desc: []bool{true}},
+		{name: "unknown field", raw: "color", wantErr: true},
+		{name: "duplicate field", raw: "name,-name", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, normalized, err := parseSort(tt.raw, testSortColumns, "-created_at", "id")
+			if tt.wantErr {
+				if !errors.Is(err, apperrors.ErrInvalidQuery) {
+					t.Fatalf("err = %v, want ErrInvalidQuery", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if normalized != tt.normalized {
+				t.Errorf("normalized = %q, want %q", normalized, tt.normalized)
+			}
+			var columns []string
+			var desc []bool
+			for _, k := range keys {
+				columns = append(columns, k.column)
+				desc = append(desc, k.desc)
+			}
+			if !reflect.DeepEqual(columns, tt.columns) || !reflect.DeepEqual(desc, tt.desc) {
+				t.Errorf("keys = %v %v, want %v %v", columns, desc, tt.columns, tt.desc)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	row := testRow{
+		ID:        uuid.MustParse("6f1c7f43-3b1c-4a53-9b8e-1c2d3e4f5a6b"),
+		Name:      "Model S",
+		Price:     79999.99,
+		CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC),
+	}
+	keys, sort, err := parseSort("-price,name,created_at", testSortColumns, "-created_at", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backward := range []bool{false, true} {
+		cursor := encodeCursor(sort, keys, &row, backward)
+		values, back, err := decodeCursor(cursor, sort, keys)
+		if err != nil {
+			t.Fatalf("decodeCursor: %v", err)
+		}
+		if back != backward {
+			t.Errorf("backward = %v, want %v", back, backward)
+		}
+		want := []any{row.Price, row.Name, row.CreatedAt, row.ID}
+		if !reflect.DeepEqual(values, want) {
+			t.Errorf("values = %v, want %v", values, want)
+		}
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	keys, sort, _ := parseSort("name", testSortColumns, "-created_at", "id")
+	otherKeys, otherSort, _ := parseSort("price", testSortColumns, "-created_at", "id")
+	row := testRow{ID: uuid.New(), Name: "x"}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "!!!"},
+		{name: "not json", cursor: base64.RawURLEncoding.EncodeToString([]byte("nope"))},
+		{name: "other sort", cursor: encodeCursor(otherSort, otherKeys, &row, false)},
+		{name: "bad value", cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"name,id","v":["x","not-a-uuid"]}`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCursor(tt.cursor, sort, keys); !errors.Is(err, apperrors.ErrInvalidQuery) {
+				t.Errorf("err = %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	keys, _, _ := parseSort("-price,name", testSortColumns, "-created_at", "id")
+	values := []any{10.5, "a", "id-1"}
+
+	tests := []struct {
+		name     string
+		backward bool
+		cond     string
+		order    string
+	}{
+		{
+			name:  "forward",
+			cond:  "(price < ?) OR (price = ? AND name > ?) OR (price = ? AND name = ? AND id > ?)",
+			order: "price DESC, name ASC, id ASC",
+		},
+		{
+			name:     "backward",
+			backward: true,
+			cond:     "(price > ?) OR (price = ? AND name < ?) OR (price = ? AND name = ? AND id < ?)",
+			order:    "price ASC, name DESC, id DESC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, args := keysetCondition(keys, values, tt.backward)
+			if cond != tt.cond {
+				t.Errorf("cond = %q, want %q", cond, tt.cond)
+			}
+			wantArgs := []any{10.5, 10.5, "a", 10.5, "a", "id-1"}
+			if !reflect.DeepEqual(args, wantArgs) {
+				t.Errorf("args = %v, want %v", args, wantArgs)
+			}
+			if order := orderClause(keys, tt.backward); order != tt.order {
+				t.Errorf("order = %q, want %q", order, tt.order)
+			}
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"plain":   "plain",
+		"50%_off": `50\%\_off`,
+		`a\b`:     `a\\b`,
+	}
+	for in, want := range tests {
+		if got := escapeLike(in); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
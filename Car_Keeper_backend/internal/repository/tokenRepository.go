@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"Car_Keeper/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrTokenReused is returned when a refresh token that was already rotated or revoked is presented again
+var ErrTokenReused = errors.New("refresh token reuse detected")
+
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, old *models.RefreshToken, next *models.RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeID(ctx context.Context, id string, expiresAt time.Time) error
+	RevokeUserFamilies(ctx context.Context, userID uint) ([]uuid.UUID, error)
+	IsRevoked(ctx context.Context, id string) (bool, error)
+	DeleteExpired(ctx context.Context, before time.Time) (revocations int64, refreshTokens int64, err error)
+}
+
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+	return &tokenRepository{db: db}
+}
+
+func (r *tokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	tracer := otel.Tracer("TokenRepository")
+	ctx, span := tracer.Start(ctx, "CreateRefreshToken-Repository")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *tokenRepository) GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	tracer := otel.Tracer("TokenRepository")
+	ctx, span := tracer.Start(ctx, "GetRefreshTokenByHash-Repository")
+	defer span.End()
+
+	var token models.RefreshToken
+	if err := r.db.WithContext(ctx).First(&token, "token_hash = ?", hash).Error; err != nil {
+		return nil, translateError(err, "refresh token")
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken marks old as replaced by next and stores next in one transaction.
+// If old was already used by a concurrent request ErrTokenReused is returned.
+func (r *tokenRepository) RotateRefreshToken(ctx context.Context, old *models.RefreshToken, next *models.RefreshToken) error {
+	tracer := otel.Tracer("TokenRepository")
+	ctx, span := tracer.Start(ctx, "RotateRefreshToken-Repository")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND replaced_by IS NULL AND revoked_at IS NULL", old.ID).
+			Update("replaced_by", next.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenReused
+		}
+		return nil
+	})
+}
+
+// RevokeFamily revokes every refresh token of a session and puts the session on the revocation list
+func (r *tokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	tracer := otel.Tracer("TokenRepository")
+	ctx, span := tracer.Start(ctx, "RevokeFamily-Repository")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var expiresAt time.Time
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ?", familyID).
+			Select("COALESCE(MAX(expires_at), NOW())").
+			Scan(&expiresAt).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.RevokedToken{ID: familyID.String(), ExpiresAt: expiresAt}).Error
+	})
+}
+
+// RevokeUserFamilies revokes every live session of a user like RevokeFamily and
+// returns their IDs
+func (r *tokenRepository) RevokeUserFamilies(ctx context.Context, userID uint) ([]uuid.UUID, error) {
+	tracer := otel.Tracer("TokenRepository")
+	ctx, span := tracer.Start(ctx, "RevokeUserFamilies-Repository")
+	defer span.End()
+
+	var ids []uuid.UUID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var families []struct {
+			FamilyID  uuid.UUID
+			ExpiresAt time.Time
+		}
+		if err := tx.Model(&models.RefreshToken{}).
+			Select("family_id, MAX(expires_at) AS expires_at").
+			Where("user_id = ?", userID).
+			Group("family_id").
+			Having("BOOL_OR(revoked_at IS NULL) AND MAX(expires_at) > NOW()").
+			Scan(&families).Error; err != nil {
+			return err
+		}
+		if len(families) == 0 {
+			return nil
+		}
+
+		ids = make([]uuid.UUID, len(families))
+		revoked := make([]models.RevokedToken, len(families))
+		for i, f := range families {
+			ids[i] = f.FamilyID
+			revoked[i] = models.RevokedToken{ID: f.FamilyID.String(), ExpiresAt: f.ExpiresAt}
+		}
+
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("family_id IN ? AND revoked_at IS NULL", ids).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *tokenRepository) RevokeID(ctx context.Context, id string, expiresAt time.Time) error {
+	tracer := otel.Tracer("TokenRepository")
+	ctx, span := tracer.Start(ctx, "RevokeID-Repository")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{ID: id, ExpiresAt: expiresAt}).Error
+}
+
+func (r *tokenRepository) IsRevoked(ctx context.Context, id string) (bool, error) {
+	tracer := otel.Tracer("TokenRepository")
+	ctx, span := tracer.Start(ctx, "IsRevoked-Repository")
+	defer span.End()
+
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.RevokedToken{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// DeleteExpired removes revocations and refresh tokens that expired before
+// before, the tokens they concern are rejected for their expiry anyway
+func (r *tokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, int64, error) {
+	tracer := otel.Tracer("TokenRepository")
+	ctx, span := tracer.Start(ctx, "DeleteExpired-Repository")
+	defer span.End()
+
+	revocations := r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.RevokedToken{})
+	if revocations.Error != nil {
+		return 0, 0, revocations.Error
+	}
+	refreshTokens := r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.RefreshToken{})
+	if refreshTokens.Error != nil {
+		return revocations.RowsAffected, 0, refreshTokens.Error
+	}
+	return revocations.RowsAffected, refreshTokens.RowsAffected, nil
+}
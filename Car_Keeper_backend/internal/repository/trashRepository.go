@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/models"
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+)
+
+type trashRepository struct {
+	db *gorm.DB
+}
+
+// TrashRepository reads and manages soft-deleted cars and engines
+type TrashRepository interface {
+	ListDeletedCars(ctx context.Context, req *models.PageRequest) (*models.Page[models.Car], error)
+	ListDeletedEngines(ctx context.Context, req *models.PageRequest) (*models.Page[models.Engine], error)
+	RestoreCar(ctx context.Context, id string) error
+	RestoreEngine(ctx context.Context, id string) error
+	PurgeCar(ctx context.Context, id string) error
+	PurgeEngine(ctx context.Context, id string) error
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (cars int64, engines int64, err error)
+}
+
+func NewTrashRepository(db *gorm.DB) TrashRepository {
+	return &trashRepository{db: db}
+}
+
+// The trash listings sort like the normal ones and additionally by deletion time
+var (
+	deletedCarSortColumns = func() map[string]sortableColumn[models.Car] {
+		columns := maps.Clone(carSortColumns)
+		columns["deleted_at"] = sortableColumn[models.Car]{column: "cars.deleted_at", kind: kindTime, value: func(c *models.Car) any { return c.DeletedAt.Time }}
+		return columns
+	}()
+	deletedEngineSortColumns = map[string]sortableColumn[models.Engine]{
+		"engine_id":  {column: "engines.engine_id", kind: kindUUID, value: func(e *models.Engine) any { return e.EngineID }},
+		"created_at": {column: "engines.created_at", kind: kindTime, value: func(e *models.Engine) any { return e.CreatedAt }},
+		"deleted_at": {column: "engines.deleted_at", kind: kindTime, value: func(e *models.Engine) any { return e.DeletedAt.Time }},
+	}
+)
+
+func (r *trashRepository) ListDeletedCars(ctx context.Context, req *models.PageRequest) (*models.Page[models.Car], error) {
+	tracer := otel.Tracer("TrashRepository")
+	ctx, span := tracer.Start(ctx, "ListDeletedCars-Repository")
+	defer span.End()
+
+	keys, sort, err := parseSort(req.Sort, deletedCarSortColumns, "-deleted_at", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	db := r.db.WithContext(ctx).Unscoped().Model(&models.Car{}).Where("cars.deleted_at IS NOT NULL")
+	return paginate(db.Session(&gorm.Session{}), *req, keys, sort, func(tx *gorm.DB) *gorm.DB {
+		// The engine may be in the trash as well
+		return tx.Preload("Engine", func(db *gorm.DB) *gorm.DB { return db.Unscoped() })
+	})
+}
+
+func (r *trashRepository) ListDeletedEngines(ctx context.Context, req *models.PageRequest) (*models.Page[models.Engine], error) {
+	tracer := otel.Tracer("TrashRepository")
+	ctx, span := tracer.Start(ctx, "ListDeletedEngines-Repository")
+	defer span.End()
+
+	keys, sort, err := parseSort(req.Sort, deletedEngineSortColumns, "-deleted_at", "engine_id")
+	if err != nil {
+		return nil, err
+	}
+
+	db := r.db.WithContext(ctx).Unscoped().Model(&models.Engine{}).Where("engines.deleted_at IS NOT NULL")
+	return paginate(db.Session(&gorm.Session{}), *req, keys, sort, func(tx *gorm.DB) *gorm.DB { return tx })
+}
+
+// RestoreCar takes a car out of the trash. Its engine has to be restored first.
+func (r *trashRepository) RestoreCar(ctx context.Context, id string) error {
+	tracer := otel.Tracer("TrashRepository")
+	ctx, span := tracer.Start(ctx, "RestoreCar-Repository")
+	defer span.End()
+
+	carID, err := uuid.Parse(id)
+	if err != nil {
+		return apperrors.InvalidID("car", err)
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var car models.Car
+		if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", carID).First(&car).Error; err != nil {
+			return translateError(err, "deleted car")
+		}
+
+		var engines int64
+		if err := tx.Model(&models.Engine{}).Where("engine_id = ?", car.EngineID).Count(&engines).Error; err != nil {
+			return err
+		}
+		if engines == 0 {
+			return apperrors.FieldError(apperrors.ErrConflict, "engine_id", "the engine of this car is deleted, restore it first")
+		}
+
+		return tx.Unscoped().Model(&models.Car{}).
+			Where("id = ?", carID).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")}).Error
+	})
+}
+
+func (r *trashRepository) RestoreEngine(ctx context.Context, id string) error {
+	tracer := otel.Tracer("TrashRepository")
+	ctx, span := tracer.Start(ctx, "RestoreEngine-Repository")
+	defer span.End()
+
+	engineID, err := uuid.Parse(id)
+	if err != nil {
+		return apperrors.InvalidID("engine", err)
+	}
+
+	result := r.db.WithContext(ctx).Unscoped().Model(&models.Engine{}).
+		Where("engine_id = ? AND deleted_at IS NOT NULL", engineID).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return translateError(result.Error, "engine")
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.NotFound("deleted engine")
+	}
+	return nil
+}
+
+// PurgeCar permanently deletes a car that is in the trash
+func (r *trashRepository) PurgeCar(ctx context.Context, id string) error {
+	tracer := otel.Tracer("TrashRepository")
+	ctx, span := tracer.Start(ctx, "PurgeCar-Repository")
+	defer span.End()
+
+	carID, err := uuid.Parse(id)
+	if err != nil {
+		return apperrors.InvalidID("car", err)
+	}
+
+	result := r.db.WithContext(ctx).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", carID).Delete(&models.Car{})
+	if result.Error != nil {
+		return translateError(result.Error, "car")
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.NotFound("deleted car")
+	}
+	return nil
+}
+
+// PurgeEngine permanently deletes an engine that is in the trash. The foreign key
+// cascades to its cars, so this is refused while any car uses it, trashed ones
+// included, as they could still be restored.
+func (r *trashRepository) PurgeEngine(ctx context.Context, id string) error {
+	tracer := otel.Tracer("TrashRepository")
+	ctx, span := tracer.Start(ctx, "PurgeEngine-Repository")
+	defer span.End()
+
+	engineID, err := uuid.Parse(id)
+	if err != nil {
+		return apperrors.InvalidID("engine", err)
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var usage struct {
+			Cars    int64
+			Trashed int64
+		}
+		if err := tx.Unscoped().Model(&models.Car{}).
+			Select("COUNT(*) AS cars, COUNT(deleted_at) AS trashed").
+			Where("engine_id = ?", engineID).
+			Scan(&usage).Error; err != nil {
+			return err
+		}
+		if usage.Trashed > 0 && usage.Trashed == usage.Cars {
+			return apperrors.New(apperrors.ErrConflict, fmt.Sprintf("engine is still used by %d cars in the trash, purge them first", usage.Trashed))
+		}
+		if usage.Cars > 0 {
+			return apperrors.New(apperrors.ErrConflict, fmt.Sprintf("engine is still used by %d cars", usage.Cars))
+		}
+
+		result := tx.Unscoped().Where("engine_id = ? AND deleted_at IS NOT NULL", engineID).Delete(&models.Engine{})
+		if result.Error != nil {
+			return translateError(result.Error, "engine")
+		}
+		if result.RowsAffected == 0 {
+			return apperrors.NotFound("deleted engine")
+		}
+		return nil
+	})
+}
+
+// PurgeDeletedBefore permanently deletes cars and engines that were soft-deleted
+// before cutoff. Engines still used by any car, trashed ones included, are kept.
+func (r *trashRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, int64, error) {
+	tracer := otel.Tracer("TrashRepository")
+	ctx, span := tracer.Start(ctx, "PurgeDeletedBefore-Repository")
+	defer span.End()
+
+	var cars, engines int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("deleted_at < ?", cutoff).Delete(&models.Car{})
+		if result.Error != nil {
+			return result.Error
+		}
+		cars = result.RowsAffected
+
+		inUse := tx.Unscoped().Model(&models.Car{}).Select("1").Where("cars.engine_id = engines.engine_id")
+		result = tx.Unscoped().Where("deleted_at < ? AND NOT EXISTS (?)", cutoff, inUse).Delete(&models.Engine{})
+		if result.Error != nil {
+			return result.Error
+		}
+		engines = result.RowsAffected
+		return nil
+	})
+	return cars, engines, err
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"Car_Keeper/internal/models"
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+)
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+type UserRepository interface {
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) error
+	UpdateUser(ctx context.Context, user *models.User) error
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+func (r *userRepository) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	tracer := otel.Tracer("UserRepository")
+	ctx, span := tracer.Start(ctx, "GetUserByID-Repository")
+	defer span.End()
+
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translateError(err, "user")
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	tracer := otel.Tracer("UserRepository")
+	ctx, span := tracer.Start(ctx, "GetUserByEmail-Repository")
+	defer span.End()
+
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "email = ?", email).Error; err != nil {
+		return nil, translateError(err, "user")
+	}
+	return &user, nil
+}
+
+func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
+	tracer := otel.Tracer("UserRepository")
+	ctx, span := tracer.Start(ctx, "CreateUser-Repository")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Create(user).Error, "user")
+}
+
+func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	tracer := otel.Tracer("UserRepository")
+	ctx, span := tracer.Start(ctx, "UpdateUser-Repository")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Save(user).Error, "user")
+}
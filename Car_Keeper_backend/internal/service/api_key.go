@@ -0,0 +1,120 @@
+package service
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/dto"
+	"Car_Keeper/internal/models"
+	"Car_Keeper/internal/repository"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	apiKeyPrefix = "ck_"
+	// last_used_at is only written when it is older than this, not on every request
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey = apperrors.New(apperrors.ErrUnauthorized, "invalid, expired or revoked API key")
+	ErrKeyExpiryPast = apperrors.FieldError(apperrors.ErrValidation, "expires_at", "expires_at must be in the future")
+)
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, createdBy uint, req *dto.CreateAPIKeyRequest) (*dto.CreateAPIKeyResponse, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+	Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error)
+}
+
+type apiKeyService struct {
+	repo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{repo: repo}
+}
+
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, createdBy uint, req *dto.CreateAPIKeyRequest) (*dto.CreateAPIKeyResponse, error) {
+	tracer := otel.Tracer("APIKeyService")
+	ctx, span := tracer.Start(ctx, "CreateAPIKey-Service")
+	defer span.End()
+
+	// A key that is expired from the start would be useless
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrKeyExpiryPast
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	rawKey := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	key := &models.APIKey{
+		Name:      req.Name,
+		Prefix:    rawKey[:len(apiKeyPrefix)+6],
+		KeyHash:   hashToken(rawKey),
+		Scopes:    req.Scopes,
+		CreatedBy: createdBy,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+	return &dto.CreateAPIKeyResponse{APIKey: *key, Key: rawKey}, nil
+}
+
+func (s *apiKeyService) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	tracer := otel.Tracer("APIKeyService")
+	ctx, span := tracer.Start(ctx, "ListAPIKeys-Service")
+	defer span.End()
+
+	return s.repo.ListAPIKeys(ctx)
+}
+
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	tracer := otel.Tracer("APIKeyService")
+	ctx, span := tracer.Start(ctx, "RevokeAPIKey-Service")
+	defer span.End()
+
+	keyID, err := uuid.Parse(id)
+	if err != nil {
+		return apperrors.InvalidID("API key", err)
+	}
+	return s.repo.RevokeAPIKey(ctx, keyID)
+}
+
+// Authenticate resolves the X-API-Key header value to an active key
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error) {
+	tracer := otel.Tracer("APIKeyService")
+	ctx, span := tracer.Start(ctx, "Authenticate-Service")
+	defer span.End()
+
+	key, err := s.repo.GetAPIKeyByHash(ctx, hashToken(rawKey))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.repo.TouchAPIKey(ctx, key.ID, now); err != nil {
+			log.Printf("Failed to record API key usage: %v", err)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/dto"
+	"Car_Keeper/internal/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeAPIKeyRepository records the keys it is asked to create
+type fakeAPIKeyRepository struct {
+	created []*models.APIKey
+}
+
+func (r *fakeAPIKeyRepository) CreateAPIKey(_ context.Context, key *models.APIKey) error {
+	r.created = append(r.created, key)
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) ListAPIKeys(context.Context) ([]models.APIKey, error) {
+	return nil, nil
+}
+
+func (r *fakeAPIKeyRepository) GetAPIKeyByHash(context.Context, string) (*models.APIKey, error) {
+	return nil, apperrors.NotFound("API key")
+}
+
+func (r *fakeAPIKeyRepository) RevokeAPIKey(context.Context, uuid.UUID) error { return nil }
+
+func (r *fakeAPIKeyRepository) TouchAPIKey(context.Context, uuid.UUID, time.Time) error { return nil }
+
+func TestCreateAPIKeyExpiry(t *testing.T) {
+	at := func(d time.Duration) *time.Time {
+		t := time.Now().Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		wantErr   error
+	}{
+		{name: "never expires", expiresAt: nil},
+		{name: "in the future", expiresAt: at(24 * time.Hour)},
+		{name: "in the past", expiresAt: at(-time.Hour), wantErr: ErrKeyExpiryPast},
+		{name: "right now", expiresAt: at(0), wantErr: ErrKeyExpiryPast},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeAPIKeyRepository{}
+			svc := NewAPIKeyService(repo)
+
+			_, err := svc.CreateAPIKey(context.Background(), 1, &dto.CreateAPIKeyRequest{
+				Name:      "ci",
+				Scopes:    []string{models.ScopeCarsRead},
+				ExpiresAt: tt.expiresAt,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && !errors.Is(err, apperrors.ErrValidation) {
+				t.Errorf("err = %v, want a validation error", err)
+			}
+			if created := len(repo.created) == 1; created != (tt.wantErr == nil) {
+				t.Errorf("key created = %v", created)
+			}
+		})
+	}
+}
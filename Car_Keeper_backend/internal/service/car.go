@@ -1,27 +1,35 @@
 package service
 
 import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/dto"
 	"Car_Keeper/internal/models"
 	"Car_Keeper/internal/repository"
 	"context"
+	"errors"
+	"sort"
 
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 )
 
 type carService struct {
-	repo repository.CarRepository
+	repo       repository.CarRepository
+	engineRepo repository.EngineRepository
 }
 
-func NewCarService(repo repository.CarRepository) CarService {
-	return &carService{repo: repo}
+func NewCarService(repo repository.CarRepository, engineRepo repository.EngineRepository) CarService {
+	return &carService{repo: repo, engineRepo: engineRepo}
 }
 
 type CarService interface {
 	GetCarByID(ctx context.Context, id string) (*models.Car, error)
-	GetCarByBrand(ctx context.Context, brand string) ([]models.Car, error)
-	CreateCar(ctx context.Context, car *models.CarRequest) error
-	UpdateCar(ctx context.Context, id string, car *models.CarRequest) error
-	DeleteCar(ctx context.Context, id string) error
+	ListCars(ctx context.Context, query *models.CarQuery) (*models.Page[models.Car], error)
+	ExportCars(ctx context.Context, query *models.CarQuery, fn func(*models.Car) error) error
+	CreateCar(ctx context.Context, car *models.CarRequest) (*models.Car, error)
+	UpdateCar(ctx context.Context, id string, version int64, car *models.CarRequest) (*models.Car, error)
+	DeleteCar(ctx context.Context, id string, version int64) error
+	ImportCars(ctx context.Context, carImport *dto.CarImport) (*dto.ImportResult, error)
 }
 
 func (s *carService) GetCarByID(ctx context.Context, id string) (*models.Car, error) {
@@ -32,15 +40,24 @@ func (s *carService) GetCarByID(ctx context.Context, id string) (*models.Car, er
 	return s.repo.GetCarByID(ctx, id)
 }
 
-func (s *carService) GetCarByBrand(ctx context.Context, brand string) ([]models.Car, error) {
+func (s *carService) ListCars(ctx context.Context, query *models.CarQuery) (*models.Page[models.Car], error) {
 	tracer := otel.Tracer("CarService")
-	_, span := tracer.Start(ctx, "GetCarByBrand-Service")
+	ctx, span := tracer.Start(ctx, "ListCars-Service")
 	defer span.End()
 
-	return s.repo.GetCarByBrand(ctx, brand)
+	return s.repo.ListCars(ctx, query)
 }
 
-func (s *carService) CreateCar(ctx context.Context, carReq *models.CarRequest) error {
+func (s *carService) ExportCars(ctx context.Context, query *models.CarQuery, fn func(*models.Car) error) error {
+	tracer := otel.Tracer("CarService")
+	ctx, span := tracer.Start(ctx, "ExportCars-Service")
+	defer span.End()
+
+	return s.repo.StreamCars(ctx, query, fn)
+}
+
+// CreateCar creates the car, together with its engine when one is given inline
+func (s *carService) CreateCar(ctx context.Context, carReq *models.CarRequest) (*models.Car, error) {
 	tracer := otel.Tracer("CarService")
 	ctx, span := tracer.Start(ctx, "CreateCar-Service")
 	defer span.End()
@@ -48,22 +65,126 @@ func (s *carService) CreateCar(ctx context.Context, carReq *models.CarRequest) e
 	return s.repo.CreateCar(ctx, carReq)
 }
 
-func (s *carService) UpdateCar(ctx context.Context, id string, carReq *models.CarRequest) error {
+func (s *carService) UpdateCar(ctx context.Context, id string, version int64, carReq *models.CarRequest) (*models.Car, error) {
 	tracer := otel.Tracer("CarService")
 	ctx, span := tracer.Start(ctx, "UpdateCar-Service")
 	defer span.End()
 
-	// For simplicity, we'll just call CreateCar for now.
-	// In a real application, you'd implement an UpdateCar method in the repository.
-	return s.repo.UpdateCar(ctx, id, carReq)
+	if carReq.Engine != nil {
+		return nil, apperrors.FieldError(apperrors.ErrValidation, "engine", "a new engine can only be given when creating a car, use engine_id")
+	}
+	return s.repo.UpdateCar(ctx, id, version, carReq)
 }
 
-func (s *carService) DeleteCar(ctx context.Context, id string) error {
+func (s *carService) DeleteCar(ctx context.Context, id string, version int64) error {
 	tracer := otel.Tracer("CarService")
 	ctx, span := tracer.Start(ctx, "DeleteCar-Service")
 	defer span.End()
 
 	// For simplicity, we'll just call CreateCar for now.
 	// In a real application, you'd implement a DeleteCar method in the repository.
-	return s.repo.DeleteCar(ctx, id)
+	return s.repo.DeleteCar(ctx, id, version)
+}
+
+// ImportCars resolves the engines of the parsed rows and inserts the cars. In atomic
+// mode nothing is written when any row failed; a dry run never writes.
+func (s *carService) ImportCars(ctx context.Context, carImport *dto.CarImport) (*dto.ImportResult, error) {
+	tracer := otel.Tracer("CarService")
+	ctx, span := tracer.Start(ctx, "ImportCars-Service")
+	defer span.End()
+
+	rowErrors := carImport.Errors
+	cars := make([]models.Car, 0, len(carImport.Rows))
+
+	engineIDs, err := s.importEngineIDs(ctx, carImport.Rows)
+	if err != nil {
+		return nil, err
+	}
+	bySpec := map[models.EngineRequest]uuid.UUID{}
+
+	for _, row := range carImport.Rows {
+		engineID := row.EngineID
+		if row.Engine != nil {
+			id, ok := bySpec[*row.Engine]
+			if !ok {
+				engine, err := s.engineRepo.FindEngineBySpec(ctx, row.Engine)
+				if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+					return nil, err
+				}
+				if engine != nil {
+					id = engine.EngineID
+				}
+				bySpec[*row.Engine] = id
+			}
+			if id == uuid.Nil {
+				rowErrors = append(rowErrors, dto.ImportRowError{Row: row.Row, Field: "engine", Message: "no engine matches this spec"})
+				continue
+			}
+			engineID = id
+		} else if !engineIDs[engineID] {
+			rowErrors = append(rowErrors, dto.ImportRowError{Row: row.Row, Field: "engine_id", Message: "engine does not exist"})
+			continue
+		}
+
+		cars = append(cars, models.Car{
+			Name:     row.Name,
+			Year:     row.Year,
+			Brand:    row.Brand,
+			FuelType: row.FuelType,
+			Price:    row.Price,
+			EngineID: engineID,
+		})
+	}
+
+	sort.SliceStable(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+	failed := map[int]bool{}
+	for _, e := range rowErrors {
+		failed[e.Row] = true
+	}
+
+	result := &dto.ImportResult{
+		Mode:   carImport.Mode,
+		DryRun: carImport.DryRun,
+		Total:  carImport.Total,
+		Valid:  len(cars),
+		Failed: len(failed),
+		Errors: rowErrors,
+	}
+	if result.Errors == nil {
+		result.Errors = []dto.ImportRowError{}
+	}
+	if carImport.DryRun || len(cars) == 0 || (carImport.Mode == dto.ImportModeAtomic && len(failed) > 0) {
+		return result, nil
+	}
+
+	if err := s.repo.CreateCars(ctx, cars); err != nil {
+		return nil, err
+	}
+	result.Imported = len(cars)
+	return result, nil
+}
+
+// importEngineIDs returns which of the engine ids referenced by rows exist
+func (s *carService) importEngineIDs(ctx context.Context, rows []dto.CarImportRow) (map[uuid.UUID]bool, error) {
+	seen := map[uuid.UUID]bool{}
+	var ids []uuid.UUID
+	for _, row := range rows {
+		if row.Engine == nil && !seen[row.EngineID] {
+			seen[row.EngineID] = true
+			ids = append(ids, row.EngineID)
+		}
+	}
+
+	exists := map[uuid.UUID]bool{}
+	if len(ids) == 0 {
+		return exists, nil
+	}
+	engines, err := s.engineRepo.GetEnginesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, engine := range engines {
+		exists[engine.EngineID] = true
+	}
+	return exists, nil
 }
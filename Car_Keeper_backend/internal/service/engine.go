@@ -1,18 +1,22 @@
 package service
 
 import (
+	"Car_Keeper/internal/apperrors"
 	"Car_Keeper/internal/models"
 	"Car_Keeper/internal/repository"
 	"context"
 
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 )
 
 type EngineService interface {
 	GetEngineByID(ctx context.Context, id string) (*models.Engine, error)
+	ListEngines(ctx context.Context, query *models.EngineQuery) (*models.Page[models.EngineSummary], error)
+	ExportEngines(ctx context.Context, query *models.EngineQuery, fn func(*models.EngineSummary) error) error
 	CreateEngine(ctx context.Context, engineReq *models.EngineRequest) (*models.Engine, error)
-	UpdateEngine(ctx context.Context, id string, engineReq *models.EngineRequest) (*models.Engine, error)
-	DeleteEngine(ctx context.Context, id string) error
+	UpdateEngine(ctx context.Context, id string, version int64, engineReq *models.EngineRequest) (*models.Engine, error)
+	DeleteEngine(ctx context.Context, id string, version int64, opts *models.EngineDeleteOptions) ([]uuid.UUID, error)
 }
 
 type engineService struct {
@@ -30,6 +34,22 @@ func (s *engineService) GetEngineByID(ctx context.Context, id string) (*models.E
 	return s.repo.GetEngineByID(ctx, id)
 }
 
+func (s *engineService) ListEngines(ctx context.Context, query *models.EngineQuery) (*models.Page[models.EngineSummary], error) {
+	trace := otel.Tracer("EngineService")
+	ctx, span := trace.Start(ctx, "ListEngines-Service")
+	defer span.End()
+
+	return s.repo.ListEngines(ctx, query)
+}
+
+func (s *engineService) ExportEngines(ctx context.Context, query *models.EngineQuery, fn func(*models.EngineSummary) error) error {
+	trace := otel.Tracer("EngineService")
+	ctx, span := trace.Start(ctx, "ExportEngines-Service")
+	defer span.End()
+
+	return s.repo.StreamEngines(ctx, query, fn)
+}
+
 func (s *engineService) CreateEngine(ctx context.Context, engineReq *models.EngineRequest) (*models.Engine, error) {
 	trace := otel.Tracer("EngineService")
 	ctx, span := trace.Start(ctx, "CreateEngine-Service")
@@ -46,7 +66,8 @@ func (s *engineService) CreateEngine(ctx context.Context, engineReq *models.Engi
 	return engine, nil
 }
 
-func (s *engineService) UpdateEngine(ctx context.Context, id string, engineReq *models.EngineRequest) (*models.Engine, error) {
+// UpdateEngine applies engineReq if the engine is still at version, 0 accepts any version
+func (s *engineService) UpdateEngine(ctx context.Context, id string, version int64, engineReq *models.EngineRequest) (*models.Engine, error) {
 	trace := otel.Tracer("EngineService")
 	ctx, span := trace.Start(ctx, "UpdateEngine-Service")
 	defer span.End()
@@ -55,6 +76,9 @@ func (s *engineService) UpdateEngine(ctx context.Context, id string, engineReq *
 	if err != nil {
 		return nil, err
 	}
+	if version != 0 && engine.Version != version {
+		return nil, apperrors.VersionMismatch("engine")
+	}
 
 	engine.Displacement = engineReq.Displacement
 	engine.NoOfCylinders = engineReq.NoOfCylinders
@@ -66,10 +90,14 @@ func (s *engineService) UpdateEngine(ctx context.Context, id string, engineReq *
 	return engine, nil
 }
 
-func (s *engineService) DeleteEngine(ctx context.Context, engineID string) error {
+// DeleteEngine deletes the engine and returns the IDs of the cars the strategy reassigned or deleted
+func (s *engineService) DeleteEngine(ctx context.Context, engineID string, version int64, opts *models.EngineDeleteOptions) ([]uuid.UUID, error) {
 	trace := otel.Tracer("EngineService")
 	ctx, span := trace.Start(ctx, "DeleteEngine-Service")
 	defer span.End()
 
-	return s.repo.DeleteEngine(ctx, engineID)
+	if opts.Strategy == "" {
+		opts.Strategy = models.EngineDeleteRestrict
+	}
+	return s.repo.DeleteEngine(ctx, engineID, version, opts)
 }
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/dto"
+	"Car_Keeper/internal/models"
+	"Car_Keeper/internal/repository"
+	"Car_Keeper/pkg/utils"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+)
+
+var ErrInvalidRefreshToken = apperrors.New(apperrors.ErrUnauthorized, "invalid or expired refresh token")
+
+type TokenService interface {
+	IssueTokens(ctx context.Context, user *models.User) (*dto.TokenResponse, error)
+	Refresh(ctx context.Context, refreshToken string) (*dto.TokenResponse, error)
+	Logout(ctx context.Context, claims *utils.Claims) error
+	RevokeUserSessions(ctx context.Context, userID uint) error
+	IsRevoked(ctx context.Context, id string) bool
+	PruneExpired(ctx context.Context) (revocations int64, refreshTokens int64, err error)
+	RunPruning(ctx context.Context, interval time.Duration)
+}
+
+type tokenService struct {
+	repo       repository.TokenRepository
+	users      repository.UserRepository
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+
+	// Revocations are permanent, so a positive lookup is cached until no token
+	// carrying the ID can be valid anymore; PruneExpired evicts it then
+	revoked sync.Map // ID -> time.Time
+}
+
+func NewTokenService(repo repository.TokenRepository, users repository.UserRepository, accessTTL, refreshTTL time.Duration) TokenService {
+	return &tokenService{repo: repo, users: users, accessTTL: accessTTL, refreshTTL: refreshTTL}
+}
+
+// IssueTokens starts a new session (refresh token family) for user
+func (s *tokenService) IssueTokens(ctx context.Context, user *models.User) (*dto.TokenResponse, error) {
+	tracer := otel.Tracer("TokenService")
+	ctx, span := tracer.Start(ctx, "IssueTokens-Service")
+	defer span.End()
+
+	refreshToken, record, err := s.newRefreshToken(user.ID, uuid.New())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRefreshToken(ctx, record); err != nil {
+		return nil, err
+	}
+	return s.tokenResponse(user, record.FamilyID, refreshToken)
+}
+
+// Refresh rotates a refresh token. Presenting a token that was already rotated
+// means it leaked, so the whole family is revoked.
+func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*dto.TokenResponse, error) {
+	tracer := otel.Tracer("TokenService")
+	ctx, span := tracer.Start(ctx, "Refresh-Service")
+	defer span.End()
+
+	current, err := s.repo.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if current.ReplacedBy != nil || current.RevokedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, current)
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.users.GetUserByID(ctx, current.UserID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	nextToken, next, err := s.newRefreshToken(user.ID, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.RotateRefreshToken(ctx, current, next); err != nil {
+		if errors.Is(err, repository.ErrTokenReused) {
+			return nil, s.revokeReusedFamily(ctx, current)
+		}
+		return nil, err
+	}
+	return s.tokenResponse(user, current.FamilyID, nextToken)
+}
+
+// Logout ends the session of the access token and revokes the token itself
+func (s *tokenService) Logout(ctx context.Context, claims *utils.Claims) error {
+	tracer := otel.Tracer("TokenService")
+	ctx, span := tracer.Start(ctx, "Logout-Service")
+	defer span.End()
+
+	if claims.SessionID != "" {
+		familyID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.RevokeFamily(ctx, familyID); err != nil {
+			return err
+		}
+		s.cacheRevoked(claims.SessionID, s.accessTokensExpiry())
+	}
+
+	if err := s.repo.RevokeID(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+	s.cacheRevoked(claims.ID, claims.ExpiresAt.Time)
+	return nil
+}
+
+// RevokeUserSessions ends every session of a user, their refresh tokens and the
+// access tokens issued for them stop working
+func (s *tokenService) RevokeUserSessions(ctx context.Context, userID uint) error {
+	tracer := otel.Tracer("TokenService")
+	ctx, span := tracer.Start(ctx, "RevokeUserSessions-Service")
+	defer span.End()
+
+	families, err := s.repo.RevokeUserFamilies(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, familyID := range families {
+		s.cacheRevoked(familyID.String(), s.accessTokensExpiry())
+	}
+	return nil
+}
+
+// IsRevoked implements utils.RevocationList. Lookup errors fail closed.
+func (s *tokenService) IsRevoked(ctx context.Context, id string) bool {
+	if _, ok := s.revoked.Load(id); ok {
+		return true
+	}
+
+	revoked, err := s.repo.IsRevoked(ctx, id)
+	if err != nil {
+		log.Printf("Failed to check token revocation: %v", err)
+		return true
+	}
+	if revoked {
+		s.cacheRevoked(id, s.accessTokensExpiry())
+	}
+	return revoked
+}
+
+// PruneExpired deletes the revocations and refresh tokens that have expired and
+// evicts the cached revocations no valid token can carry anymore
+func (s *tokenService) PruneExpired(ctx context.Context) (int64, int64, error) {
+	tracer := otel.Tracer("TokenService")
+	ctx, span := tracer.Start(ctx, "PruneExpired-Service")
+	defer span.End()
+
+	now := time.Now()
+	s.revoked.Range(func(id, until any) bool {
+		if until.(time.Time).Before(now) {
+			s.revoked.Delete(id)
+		}
+		return true
+	})
+	return s.repo.DeleteExpired(ctx, now)
+}
+
+// RunPruning prunes expired tokens right away and then every interval until ctx is done
+func (s *tokenService) RunPruning(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		revocations, refreshTokens, err := s.PruneExpired(ctx)
+		if err != nil {
+			log.Printf("Failed to prune expired tokens: %v", err)
+		} else if revocations > 0 || refreshTokens > 0 {
+			log.Printf("Pruned %d expired revocations and %d expired refresh tokens", revocations, refreshTokens)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *tokenService) revokeReusedFamily(ctx context.Context, token *models.RefreshToken) error {
+	log.Printf("Refresh token reuse detected for user %d, revoking session %s", token.UserID, token.FamilyID)
+	if err := s.repo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	s.cacheRevoked(token.FamilyID.String(), s.accessTokensExpiry())
+	return ErrInvalidRefreshToken
+}
+
+// cacheRevoked remembers a revoked token or session ID until the given time
+func (s *tokenService) cacheRevoked(id string, until time.Time) {
+	s.revoked.Store(id, until)
+}
+
+// accessTokensExpiry is when the access tokens valid now have all expired, e.g.
+// the last one of a revoked session
+func (s *tokenService) accessTokensExpiry() time.Time {
+	return time.Now().Add(s.accessTTL)
+}
+
+func (s *tokenService) newRefreshToken(userID uint, familyID uuid.UUID) (string, *models.RefreshToken, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	return token, &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}, nil
+}
+
+func (s *tokenService) tokenResponse(user *models.User, familyID uuid.UUID, refreshToken string) (*dto.TokenResponse, error) {
+	accessToken, err := utils.GenerateToken(user.ID, user.Role, familyID.String(), s.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTTL.Seconds()),
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/models"
+	"Car_Keeper/internal/repository"
+	"Car_Keeper/pkg/utils"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeTokenRepository keeps refresh tokens and revocations in memory
+type fakeTokenRepository struct {
+	mu      sync.Mutex
+	tokens  map[uuid.UUID]*models.RefreshToken
+	revoked map[string]time.Time
+}
+
+func newFakeTokenRepository() *fakeTokenRepository {
+	return &fakeTokenRepository{tokens: map[uuid.UUID]*models.RefreshToken{}, revoked: map[string]time.Time{}}
+}
+
+func (r *fakeTokenRepository) CreateRefreshToken(_ context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+	stored := *token
+	r.tokens[token.ID] = &stored
+	return nil
+}
+
+func (r *fakeTokenRepository) GetRefreshTokenByHash(_ context.Context, hash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.TokenHash == hash {
+			found := *t
+			return &found, nil
+		}
+	}
+	return nil, apperrors.NotFound("refresh token")
+}
+
+func (r *fakeTokenRepository) RotateRefreshToken(ctx context.Context, old *models.RefreshToken, next *models.RefreshToken) error {
+	r.mu.Lock()
+	stored := r.tokens[old.ID]
+	if stored.ReplacedBy != nil || stored.RevokedAt != nil {
+		r.mu.Unlock()
+		return repository.ErrTokenReused
+	}
+	r.mu.Unlock()
+
+	if err := r.CreateRefreshToken(ctx, next); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored.ReplacedBy = &next.ID
+	return nil
+}
+
+func (r *fakeTokenRepository) RevokeFamily(_ context.Context, familyID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revokeFamily(familyID)
+	return nil
+}
+
+func (r *fakeTokenRepository) revokeFamily(familyID uuid.UUID) {
+	now := time.Now()
+	for _, t := range r.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	r.revoked[familyID.String()] = now.Add(time.Hour)
+}
+
+func (r *fakeTokenRepository) RevokeUserFamilies(_ context.Context, userID uint) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	live := map[uuid.UUID]bool{}
+	for _, t := range r.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			live[t.FamilyID] = true
+		}
+	}
+	var ids []uuid.UUID
+	for familyID := range live {
+		r.revokeFamily(familyID)
+		ids = append(ids, familyID)
+	}
+	return ids, nil
+}
+
+func (r *fakeTokenRepository) RevokeID(_ context.Context, id string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revoked[id] = expiresAt
+	return nil
+}
+
+func (r *fakeTokenRepository) IsRevoked(_ context.Context, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.revoked[id]
+	return ok, nil
+}
+
+func (r *fakeTokenRepository) DeleteExpired(_ context.Context, before time.Time) (int64, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var revocations, refreshTokens int64
+	for id, expiresAt := range r.revoked {
+		if expiresAt.Before(before) {
+			delete(r.revoked, id)
+			revocations++
+		}
+	}
+	for id, t := range r.tokens {
+		if t.ExpiresAt.Before(before) {
+			delete(r.tokens, id)
+			refreshTokens++
+		}
+	}
+	return revocations, refreshTokens, nil
+}
+
+// fakeUserRepository only knows the users it was created with
+type fakeUserRepository struct {
+	users map[uint]*models.User
+}
+
+func (r *fakeUserRepository) GetUserByID(_ context.Context, id uint) (*models.User, error) {
+	if u, ok := r.users[id]; ok {
+		return u, nil
+	}
+	return nil, apperrors.NotFound("user")
+}
+
+func (r *fakeUserRepository) GetUserByEmail(_ context.Context, email string) (*models.User, error) {
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, apperrors.NotFound("user")
+}
+
+func (r *fakeUserRepository) CreateUser(context.Context, *models.User) error { return nil }
+
+func (r *fakeUserRepository) UpdateUser(context.Context, *models.User) error { return nil }
+
+func newTestTokenService(t *testing.T) (*tokenService, *fakeTokenRepository, *models.User) {
+	t.Helper()
+	ks, err := utils.LoadKeySet(utils.KeyConfig{Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.SetKeySet(ks)
+
+	user := &models.User{ID: 7, Email: "driver@example.com", Role: models.RoleViewer}
+	repo := newFakeTokenRepository()
+	users := &fakeUserRepository{users: map[uint]*models.User{user.ID: user}}
+	svc := NewTokenService(repo, users, time.Minute, time.Hour).(*tokenService)
+	utils.SetRevocationList(svc)
+	t.Cleanup(func() { utils.SetRevocationList(nil) })
+	return svc, repo, user
+}
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare returns the refresh token to present, given a freshly issued one
+		prepare func(t *testing.T, svc *tokenService, repo *fakeTokenRepository, issued string) string
+		wantErr bool
+		// familyRevoked is whether the session must be revoked afterwards
+		familyRevoked bool
+	}{
+		{
+			name:    "rotates a valid token",
+			prepare: func(_ *testing.T, _ *tokenService, _ *fakeTokenRepository, issued string) string { return issued },
+		},
+		{
+			name:    "unknown token",
+			prepare: func(*testing.T, *tokenService, *fakeTokenRepository, string) string { return "not-a-token" },
+			wantErr: true,
+		},
+		{
+			name: "expired token",
+			prepare: func(_ *testing.T, _ *tokenService, repo *fakeTokenRepository, issued string) string {
+				for _, tok := range repo.tokens {
+					tok.ExpiresAt = time.Now().Add(-time.Second)
+				}
+				return issued
+			},
+			wantErr: true,
+		},
+		{
+			name: "reused token revokes the family",
+			prepare: func(t *testing.T, svc *tokenService, _ *fakeTokenRepository, issued string) string {
+				if _, err := svc.Refresh(context.Background(), issued); err != nil {
+					t.Fatalf("first refresh: %v", err)
+				}
+				return issued
+			},
+			wantErr:       true,
+			familyRevoked: true,
+		},
+		{
+			name: "logged out session",
+			prepare: func(t *testing.T, svc *tokenService, repo *fakeTokenRepository, issued string) string {
+				for _, tok := range repo.tokens {
+					if err := svc.repo.RevokeFamily(context.Background(), tok.FamilyID); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return issued
+			},
+			wantErr:       true,
+			familyRevoked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo, user := newTestTokenService(t)
+			ctx := context.Background()
+
+			issued, err := svc.IssueTokens(ctx, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := utils.ValidateToken(ctx, issued.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			presented := tt.prepare(t, svc, repo, issued.RefreshToken)
+			got, err := svc.Refresh(ctx, presented)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRefreshToken) {
+					t.Fatalf("err = %v, want ErrInvalidRefreshToken", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.RefreshToken == presented {
+					t.Error("refresh token was not rotated")
+				}
+				if _, err := svc.Refresh(ctx, got.RefreshToken); err != nil {
+					t.Errorf("rotated token rejected: %v", err)
+				}
+			}
+
+			if revoked := svc.IsRevoked(ctx, claims.SessionID); revoked != tt.familyRevoked {
+				t.Errorf("session revoked = %v, want %v", revoked, tt.familyRevoked)
+			}
+			if _, err := utils.ValidateToken(ctx, issued.Token); tt.familyRevoked != errors.Is(err, utils.ErrTokenRevoked) {
+				t.Errorf("ValidateToken err = %v, want revoked = %v", err, tt.familyRevoked)
+			}
+		})
+	}
+}
+
+func TestReuseRevokesRotatedDescendants(t *testing.T) {
+	svc, _, user := newTestTokenService(t)
+	ctx := context.Background()
+
+	issued, err := svc.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := svc.Refresh(ctx, issued.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The attacker replays the first token, the legitimate client is logged out too
+	if _, err := svc.Refresh(ctx, issued.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("replay err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := svc.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("descendant err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	svc, _, user := newTestTokenService(t)
+	ctx := context.Background()
+
+	first, _ := svc.IssueTokens(ctx, user)
+	second, _ := svc.IssueTokens(ctx, user)
+	if err := svc.RevokeUserSessions(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tokens := range []string{first.Token, second.Token} {
+		if _, err := utils.ValidateToken(ctx, tokens); !errors.Is(err, utils.ErrTokenRevoked) {
+			t.Errorf("access token err = %v, want ErrTokenRevoked", err)
+		}
+	}
+	for _, refresh := range []string{first.RefreshToken, second.RefreshToken} {
+		if _, err := svc.Refresh(ctx, refresh); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("refresh err = %v, want ErrInvalidRefreshToken", err)
+		}
+	}
+}
+
+func TestPruneExpired(t *testing.T) {
+	svc, repo, user := newTestTokenService(t)
+	ctx := context.Background()
+
+	if _, err := svc.IssueTokens(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	repo.revoked["old-jti"] = time.Now().Add(-time.Minute)
+	repo.revoked["live-jti"] = time.Now().Add(time.Minute)
+
+	revocations, refreshTokens, err := svc.PruneExpired(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revocations != 1 || refreshTokens != 0 {
+		t.Errorf("pruned %d revocations and %d refresh tokens, want 1 and 0", revocations, refreshTokens)
+	}
+	if _, ok := repo.revoked["live-jti"]; !ok {
+		t.Error("live revocation was pruned")
+	}
+}
+
+func TestPruneExpiredEvictsCachedRevocations(t *testing.T) {
+	svc, _, user := newTestTokenService(t)
+	ctx := context.Background()
+
+	issued, err := svc.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := utils.ValidateToken(ctx, issued.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Logout(ctx, claims); err != nil {
+		t.Fatal(err)
+	}
+	svc.cacheRevoked("expired-jti", time.Now().Add(-time.Second))
+
+	if _, _, err := svc.PruneExpired(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id     string
+		cached bool
+	}{
+		{id: "expired-jti", cached: false},
+		{id: claims.ID, cached: true},
+		{id: claims.SessionID, cached: true},
+	}
+	for _, tt := range tests {
+		if _, ok := svc.revoked.Load(tt.id); ok != tt.cached {
+			t.Errorf("%s cached = %v, want %v", tt.id, ok, tt.cached)
+		}
+	}
+	until, _ := svc.revoked.Load(claims.ID)
+	if !until.(time.Time).Equal(claims.ExpiresAt.Time) {
+		t.Errorf("jti cached until %v, want the token expiry %v", until, claims.ExpiresAt.Time)
+	}
+}
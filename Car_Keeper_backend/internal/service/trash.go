@@ -0,0 +1,117 @@
+package service
+
+import (
+	"Car_Keeper/internal/models"
+	"Car_Keeper/internal/repository"
+	"context"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+type TrashService interface {
+	ListDeletedCars(ctx context.Context, req *models.PageRequest) (*models.Page[models.Car], error)
+	ListDeletedEngines(ctx context.Context, req *models.PageRequest) (*models.Page[models.Engine], error)
+	RestoreCar(ctx context.Context, id string) error
+	RestoreEngine(ctx context.Context, id string) error
+	PurgeCar(ctx context.Context, id string) error
+	PurgeEngine(ctx context.Context, id string) error
+	PurgeExpired(ctx context.Context) (cars int64, engines int64, err error)
+	RunRetention(ctx context.Context, interval time.Duration)
+}
+
+type trashService struct {
+	repo repository.TrashRepository
+	// retention is how long deleted items are kept, 0 keeps them forever
+	retention time.Duration
+}
+
+func NewTrashService(repo repository.TrashRepository, retentionDays int) TrashService {
+	return &trashService{repo: repo, retention: time.Duration(retentionDays) * 24 * time.Hour}
+}
+
+func (s *trashService) ListDeletedCars(ctx context.Context, req *models.PageRequest) (*models.Page[models.Car], error) {
+	tracer := otel.Tracer("TrashService")
+	ctx, span := tracer.Start(ctx, "ListDeletedCars-Service")
+	defer span.End()
+
+	return s.repo.ListDeletedCars(ctx, req)
+}
+
+func (s *trashService) ListDeletedEngines(ctx context.Context, req *models.PageRequest) (*models.Page[models.Engine], error) {
+	tracer := otel.Tracer("TrashService")
+	ctx, span := tracer.Start(ctx, "ListDeletedEngines-Service")
+	defer span.End()
+
+	return s.repo.ListDeletedEngines(ctx, req)
+}
+
+func (s *trashService) RestoreCar(ctx context.Context, id string) error {
+	tracer := otel.Tracer("TrashService")
+	ctx, span := tracer.Start(ctx, "RestoreCar-Service")
+	defer span.End()
+
+	return s.repo.RestoreCar(ctx, id)
+}
+
+func (s *trashService) RestoreEngine(ctx context.Context, id string) error {
+	tracer := otel.Tracer("TrashService")
+	ctx, span := tracer.Start(ctx, "RestoreEngine-Service")
+	defer span.End()
+
+	return s.repo.RestoreEngine(ctx, id)
+}
+
+func (s *trashService) PurgeCar(ctx context.Context, id string) error {
+	tracer := otel.Tracer("TrashService")
+	ctx, span := tracer.Start(ctx, "PurgeCar-Service")
+	defer span.End()
+
+	return s.repo.PurgeCar(ctx, id)
+}
+
+func (s *trashService) PurgeEngine(ctx context.Context, id string) error {
+	tracer := otel.Tracer("TrashService")
+	ctx, span := tracer.Start(ctx, "PurgeEngine-Service")
+	defer span.End()
+
+	return s.repo.PurgeEngine(ctx, id)
+}
+
+// PurgeExpired permanently deletes everything that has been in the trash longer than the retention
+func (s *trashService) PurgeExpired(ctx context.Context) (int64, int64, error) {
+	tracer := otel.Tracer("TrashService")
+	ctx, span := tracer.Start(ctx, "PurgeExpired-Service")
+	defer span.End()
+
+	if s.retention == 0 {
+		return 0, 0, nil
+	}
+	return s.repo.PurgeDeletedBefore(ctx, time.Now().Add(-s.retention))
+}
+
+// RunRetention purges expired trash right away and then every interval until ctx is done.
+// It returns immediately when the retention is disabled.
+func (s *trashService) RunRetention(ctx context.Context, interval time.Duration) {
+	if s.retention == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cars, engines, err := s.PurgeExpired(ctx)
+		if err != nil {
+			log.Printf("Failed to purge expired trash: %v", err)
+		} else if cars > 0 || engines > 0 {
+			log.Printf("Purged %d cars and %d engines from the trash", cars, engines)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
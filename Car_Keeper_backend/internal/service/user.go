@@ -0,0 +1,194 @@
+package service
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/dto"
+	"Car_Keeper/internal/models"
+	"Car_Keeper/internal/repository"
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	ErrEmailTaken         = apperrors.FieldError(apperrors.ErrConflict, "email", "email is already registered")
+	ErrInvalidCredentials = apperrors.New(apperrors.ErrUnauthorized, "invalid email or password")
+	ErrWrongPassword      = apperrors.FieldError(apperrors.ErrValidation, "current_password", "current password is incorrect")
+)
+
+// unknownUser has a password hash of the same cost as real accounts. Logins for
+// an unknown email are checked against it, so their response time does not
+// reveal which emails are registered.
+var unknownUser = sync.OnceValue(func() *models.User {
+	user := &models.User{}
+	if err := user.SetPassword(rand.Text()); err != nil {
+		panic(err)
+	}
+	return user
+})
+
+type UserService interface {
+	Register(ctx context.Context, req *dto.RegisterRequest) (*dto.UserResponse, error)
+	Login(ctx context.Context, req *dto.LoginRequest) (*dto.LoginResponse, error)
+	GetUserByID(ctx context.Context, id uint) (*dto.UserResponse, error)
+	UpdateUser(ctx context.Context, id uint, req *dto.UpdateUserRequest) (*dto.UserResponse, error)
+	ChangePassword(ctx context.Context, id uint, req *dto.ChangePasswordRequest) error
+	UpdateRole(ctx context.Context, id uint, req *dto.UpdateRoleRequest) (*dto.UserResponse, error)
+}
+
+type userService struct {
+	repo   repository.UserRepository
+	tokens TokenService
+}
+
+func NewUserService(repo repository.UserRepository, tokens TokenService) UserService {
+	return &userService{repo: repo, tokens: tokens}
+}
+
+func (s *userService) Register(ctx context.Context, req *dto.RegisterRequest) (*dto.UserResponse, error) {
+	tracer := otel.Tracer("UserService")
+	ctx, span := tracer.Start(ctx, "Register-Service")
+	defer span.End()
+
+	email := normalizeEmail(req.Email)
+	if _, err := s.repo.GetUserByEmail(ctx, email); err == nil {
+		return nil, ErrEmailTaken
+	} else if !errors.Is(err, apperrors.ErrNotFound) {
+		return nil, err
+	}
+
+	user := &models.User{
+		Email: email,
+		Name:  req.Name,
+		Phone: req.Phone,
+		Role:  models.RoleViewer,
+	}
+	if err := user.SetPassword(req.Password); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return toUserResponse(user), nil
+}
+
+func (s *userService) Login(ctx context.Context, req *dto.LoginRequest) (*dto.LoginResponse, error) {
+	tracer := otel.Tracer("UserService")
+	ctx, span := tracer.Start(ctx, "Login-Service")
+	defer span.End()
+
+	user, err := s.repo.GetUserByEmail(ctx, normalizeEmail(req.Email))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			unknownUser().CheckPassword(req.Password)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if !user.CheckPassword(req.Password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	tokens, err := s.tokens.IssueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.LoginResponse{TokenResponse: *tokens, User: *toUserResponse(user)}, nil
+}
+
+func (s *userService) GetUserByID(ctx context.Context, id uint) (*dto.UserResponse, error) {
+	tracer := otel.Tracer("UserService")
+	ctx, span := tracer.Start(ctx, "GetUserByID-Service")
+	defer span.End()
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toUserResponse(user), nil
+}
+
+func (s *userService) UpdateUser(ctx context.Context, id uint, req *dto.UpdateUserRequest) (*dto.UserResponse, error) {
+	tracer := otel.Tracer("UserService")
+	ctx, span := tracer.Start(ctx, "UpdateUser-Service")
+	defer span.End()
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only overwrite the fields that were sent
+	if req.Name != "" {
+		user.Name = req.Name
+	}
+	if req.Phone != "" {
+		user.Phone = req.Phone
+	}
+
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return toUserResponse(user), nil
+}
+
+func (s *userService) ChangePassword(ctx context.Context, id uint, req *dto.ChangePasswordRequest) error {
+	tracer := otel.Tracer("UserService")
+	ctx, span := tracer.Start(ctx, "ChangePassword-Service")
+	defer span.End()
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !user.CheckPassword(req.CurrentPassword) {
+		return ErrWrongPassword
+	}
+	if err := user.SetPassword(req.NewPassword); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	// Sessions opened with the old password could have been stolen
+	return s.tokens.RevokeUserSessions(ctx, user.ID)
+}
+
+func (s *userService) UpdateRole(ctx context.Context, id uint, req *dto.UpdateRoleRequest) (*dto.UserResponse, error) {
+	tracer := otel.Tracer("UserService")
+	ctx, span := tracer.Start(ctx, "UpdateRole-Service")
+	defer span.End()
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Role = req.Role
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	// Access tokens carry the role, end the sessions so it takes effect right away
+	if err := s.tokens.RevokeUserSessions(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	return toUserResponse(user), nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func toUserResponse(user *models.User) *dto.UserResponse {
+	return &dto.UserResponse{
+		ID:    user.ID,
+		Email: user.Email,
+		Name:  user.Name,
+		Phone: user.Phone,
+		Role:  user.Role,
+	}
+}
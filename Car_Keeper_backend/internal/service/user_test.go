@@ -0,0 +1,73 @@
+package service
+
+import (
+	"Car_Keeper/internal/apperrors"
+	"Car_Keeper/internal/dto"
+	"Car_Keeper/internal/models"
+	"context"
+	"errors"
+	"testing"
+)
+
+func newTestUserService(t *testing.T) (UserService, *models.User) {
+	t.Helper()
+	tokens, _, _ := newTestTokenService(t)
+	user := &models.User{ID: 3, Email: "owner@example.com", Role: models.RoleViewer}
+	if err := user.SetPassword("correct-horse"); err != nil {
+		t.Fatal(err)
+	}
+	users := &fakeUserRepository{users: map[uint]*models.User{user.ID: user}}
+	return NewUserService(users, tokens), user
+}
+
+func TestLogin(t *testing.T) {
+	svc, _ := newTestUserService(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{name: "valid", email: "owner@example.com", password: "correct-horse"},
+		{name: "email is case insensitive", email: "Owner@Example.com", password: "correct-horse"},
+		{name: "wrong password", email: "owner@example.com", password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "unknown email", email: "nobody@example.com", password: "correct-horse", wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.Login(context.Background(), &dto.LoginRequest{Email: tt.email, Password: tt.password})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Token == "" || got.User.Email != "owner@example.com" {
+				t.Errorf("unexpected response %+v", got)
+			}
+		})
+	}
+}
+
+func TestUnknownUserHasARealHash(t *testing.T) {
+	if unknownUser().PasswordHash == "" || unknownUser().CheckPassword("") {
+		t.Error("unknown user accepts a password")
+	}
+}
+
+func TestChangePasswordWrongCurrentPassword(t *testing.T) {
+	svc, user := newTestUserService(t)
+
+	err := svc.ChangePassword(context.Background(), user.ID, &dto.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "another-one"})
+	if !errors.Is(err, apperrors.ErrValidation) {
+		t.Fatalf("err = %v, want a validation error", err)
+	}
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) || appErr.Field != "current_password" {
+		t.Errorf("err = %#v, want a current_password field error", err)
+	}
+}
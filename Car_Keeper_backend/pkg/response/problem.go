@@ -0,0 +1,49 @@
+package response
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	TraceID  string       `json:"trace_id,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError points at one invalid field of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// WriteProblem aborts the request with p, filling in the type, title,
+// instance and trace id when they are not set.
+func WriteProblem(c *gin.Context, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	if p.TraceID == "" {
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+			p.TraceID = sc.TraceID().String()
+		}
+	}
+
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
@@ -8,7 +8,6 @@ type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
 }
 
 func Success(c *gin.Context, status int, message string, data interface{}) {
@@ -19,9 +18,7 @@ func Success(c *gin.Context, status int, message string, data interface{}) {
 	})
 }
 
+// Error aborts the request with a problem+json body
 func Error(c *gin.Context, status int, message string) {
-	c.JSON(status, Response{
-		Success: false,
-		Error:   message,
-	})
+	WriteProblem(c, Problem{Status: status, Detail: message})
 }
@@ -1,53 +1,78 @@
 package utils
 
 import (
+	"context"
 	"errors"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+var ErrTokenRevoked = errors.New("token has been revoked")
+
 type Claims struct {
-	UserID uint `json:"user_id"`
+	UserID    uint   `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key-change-this"
+// RevocationList reports whether a token ID (jti) or session ID has been revoked
+type RevocationList interface {
+	IsRevoked(ctx context.Context, id string) bool
+}
+
+var revocationList RevocationList
+
+// SetRevocationList makes ValidateToken reject tokens found in list
+func SetRevocationList(list RevocationList) {
+	revocationList = list
+}
+
+// GenerateToken issues an access token for a login session that expires after ttl
+func GenerateToken(userID uint, role, sessionID string, ttl time.Duration) (string, error) {
+	if keySet == nil {
+		return "", ErrNoKeySet
 	}
 
+	now := time.Now()
 	claims := Claims{
-		UserID: userID,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return keySet.sign(claims)
 }
 
-func ValidateToken(tokenString string) (uint, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key-change-this"
+// ValidateToken verifies tokenString and checks the revocation list within ctx
+func ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
+	if keySet == nil {
+		return nil, ErrNoKeySet
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keySet.keyFunc, jwt.WithValidMethods(keySet.validMethods()))
 
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims.UserID, nil
+	if revocationList != nil {
+		if revocationList.IsRevoked(ctx, claims.ID) || (claims.SessionID != "" && revocationList.IsRevoked(ctx, claims.SessionID)) {
+			return nil, ErrTokenRevoked
+		}
 	}
 
-	return 0, errors.New("invalid token")
+	return claims, nil
 }
@@ -0,0 +1,278 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyConfig describes where the JWT keys come from. When SigningKeyFile is empty
+// tokens are signed with HS256 and Secret, and no public keys are published.
+type KeyConfig struct {
+	Secret         string
+	SigningKeyFile string
+	SigningKeyID   string
+	// VerificationKeyFiles are older public keys still accepted during a rotation,
+	// either "path" or "kid=path"
+	VerificationKeyFiles []string
+}
+
+type verificationKey struct {
+	id     string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeySet holds the active signing key and every key accepted for verification
+type KeySet struct {
+	method     jwt.SigningMethod
+	signingKey interface{}
+	keyID      string
+	verify     map[string]verificationKey
+}
+
+// JWK is a public key in RFC 7517 format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var (
+	ErrNoKeySet = errors.New("JWT keys are not configured")
+
+	keySet *KeySet
+)
+
+// SetKeySet configures the keys used by GenerateToken and ValidateToken
+func SetKeySet(ks *KeySet) {
+	keySet = ks
+}
+
+// LoadKeySet reads the signing and verification keys from PEM files.
+// RSA keys sign with RS256 and Ed25519 keys with EdDSA.
+func LoadKeySet(cfg KeyConfig) (*KeySet, error) {
+	if cfg.SigningKeyFile == "" {
+		if cfg.Secret == "" {
+			return nil, errors.New("either a JWT secret or a signing key file is required")
+		}
+		return &KeySet{method: jwt.SigningMethodHS256, signingKey: []byte(cfg.Secret)}, nil
+	}
+
+	data, err := os.ReadFile(cfg.SigningKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	private, public, err := parsePEMKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", cfg.SigningKeyFile, err)
+	}
+	if private == nil {
+		return nil, fmt.Errorf("signing key %s is not a private key", cfg.SigningKeyFile)
+	}
+
+	signing, err := newVerificationKey(cfg.SigningKeyID, public)
+	if err != nil {
+		return nil, err
+	}
+	ks := &KeySet{
+		method:     signing.method,
+		signingKey: private,
+		keyID:      signing.id,
+		verify:     map[string]verificationKey{signing.id: signing},
+	}
+
+	for _, entry := range cfg.VerificationKeyFiles {
+		kid, path := "", entry
+		if before, after, ok := strings.Cut(entry, "="); ok {
+			kid, path = before, after
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read verification key: %w", err)
+		}
+		_, public, err := parsePEMKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse verification key %s: %w", path, err)
+		}
+		key, err := newVerificationKey(kid, public)
+		if err != nil {
+			return nil, err
+		}
+		ks.verify[key.id] = key
+	}
+
+	return ks, nil
+}
+
+// JWKS returns the public verification keys, none in HS256 mode
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range ks.verify {
+		jwk, _ := toJWK(key.id, key.public)
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.method, claims)
+	if ks.keyID != "" {
+		token.Header["kid"] = ks.keyID
+	}
+	return token.SignedString(ks.signingKey)
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	if ks.verify == nil {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return ks.signingKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.public, nil
+}
+
+func (ks *KeySet) validMethods() []string {
+	if ks.verify == nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+	return []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+}
+
+func newVerificationKey(kid string, public crypto.PublicKey) (verificationKey, error) {
+	var method jwt.SigningMethod
+	switch public.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported key type %T", public)
+	}
+
+	if kid == "" {
+		thumbprint, err := keyThumbprint(public)
+		if err != nil {
+			return verificationKey{}, err
+		}
+		kid = thumbprint
+	}
+	return verificationKey{id: kid, method: method, public: public}, nil
+}
+
+// parsePEMKey accepts PKCS#8, PKCS#1 and PKIX encoded RSA or Ed25519 keys.
+// private is nil when the PEM only holds a public key.
+func parsePEMKey(data []byte) (private crypto.Signer, public crypto.PublicKey, err error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return key, &key.PublicKey, nil
+	case ed25519.PrivateKey:
+		return key, key.Public(), nil
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return nil, key, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+func toJWK(kid string, public crypto.PublicKey) (JWK, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", public)
+	}
+}
+
+// keyThumbprint computes the RFC 7638 thumbprint, used as the default kid
+func keyThumbprint(public crypto.PublicKey) (string, error) {
+	jwk, err := toJWK("", public)
+	if err != nil {
+		return "", err
+	}
+
+	// Required members only, in lexicographic order
+	var members map[string]string
+	if jwk.Kty == "RSA" {
+		members = map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N}
+	} else {
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X}
+	}
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,5 @@
+#!/bin/sh
+# Runs the schema migrations, e.g. scripts/migrate.sh up | down 1 | status | create add_cars_color
+set -e
+cd "$(dirname "$0")/.."
+exec go run ./cmd/api migrate "$@"
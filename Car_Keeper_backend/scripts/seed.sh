@@ -0,0 +1,5 @@
+#!/bin/sh
+# Loads fixture files into the database, e.g. scripts/seed.sh or scripts/seed.sh fixtures/dev.yaml
+set -e
+cd "$(dirname "$0")/.."
+exec go run ./cmd/api seed "$@"